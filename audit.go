@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+type auditRecord struct { // one line of the audit log
+	Timestamp  string `json:"timestamp"`
+	SessionID  int64  `json:"session_id"`
+	RemoteAddr string `json:"remote_addr"`
+	Action     string `json:"action"`
+	Target     string `json:"target,omitempty"`
+	Result     string `json:"result"`
+}
+
+type auditLogger struct { // auditLogger object, shared by all sessions
+	mu   sync.Mutex
+	file *os.File
+}
+
+var audit *auditLogger // nil when admin features are disabled
+
+func newAuditLogger(path string) (*auditLogger, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600) // append only, never truncated
+	if err != nil {
+		return nil, err
+	}
+	return &auditLogger{file: file}, nil
+}
+
+func (al *auditLogger) Log(record auditRecord) error { // writes one JSON record and syncs it to disk
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	if _, err := al.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return al.file.Sync()
+}
+
+func (al *auditLogger) Close() {
+	al.file.Close()
+}
+
+func auditAction(session *clientSession, action, target string, success bool) { // records an admin action if auditing is on
+	if audit == nil {
+		return
+	}
+
+	result := "success"
+	if !success {
+		result = "failure"
+	}
+	err := audit.Log(auditRecord{
+		Timestamp:  logTimestamp(),
+		SessionID:  session.id,
+		RemoteAddr: session.conn.RemoteAddr().String(),
+		Action:     action,
+		Target:     target,
+		Result:     result,
+	})
+	if err != nil {
+		fmt.Printf("[%s] Failed to write audit record: %v\n", logTimestamp(), err)
+	}
+}
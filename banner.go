@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+)
+
+var reloadedBanner atomic.Pointer[string] // replaces cfg.bannerText once -banner-file has been reloaded
+
+func currentBanner() string { // the greeting to send on connect, "" for none
+	if banner := reloadedBanner.Load(); banner != nil {
+		return *banner
+	}
+	return cfg.bannerText
+}
+
+func loadBannerFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	banner := strings.TrimRight(string(data), "\r\n") // writeLine adds the final newline
+	if len(banner) > maxBannerSize {
+		return "", fmt.Errorf("%s is %d bytes, the limit is %d", path, len(banner), maxBannerSize)
+	}
+	return banner, nil
+}
+
+// watchBannerReloads re-reads -banner-file whenever one of reloadSignals
+// arrives. Only the banner changes; every other setting keeps the value it
+// had at startup. Runs forever, so start it in its own goroutine.
+func watchBannerReloads() {
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, reloadSignals...)
+	for range reload {
+		banner, err := loadBannerFile(cfg.bannerFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[%s] Warning: banner not reloaded, keeping the old one: %v\n", logTimestamp(), err)
+			continue
+		}
+		reloadedBanner.Store(&banner)
+		if !cfg.quiet {
+			fmt.Printf("[%s] Banner reloaded from %s via SIGWINCH.\n", logTimestamp(), cfg.bannerFile)
+		}
+	}
+}
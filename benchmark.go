@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+const (
+	defaultBenchmark = 5 * time.Second
+	maxBenchmark     = 30 * time.Second
+	benchmarkMsgSize = 64
+)
+
+func handleBenchmark(session *clientSession, args string) error {
+	d := defaultBenchmark
+	if args != "" {
+		var err error
+		if d, err = time.ParseDuration(args); err != nil || d <= 0 {
+			return writeLine(session, "Usage: /benchmark [duration] (e.g. /benchmark 2s)")
+		}
+	}
+	if d > maxBenchmark {
+		return writeLine(session, fmt.Sprintf("Benchmark cannot be longer than %s.", maxBenchmark))
+	}
+
+	ops, elapsed, err := runPipeBenchmark(d)
+	if err != nil {
+		return writeLine(session, fmt.Sprintf("Benchmark failed: %v", err))
+	}
+	var avg time.Duration
+	if ops > 0 {
+		avg = elapsed / time.Duration(ops)
+	}
+	return writeLine(session, fmt.Sprintf("Benchmark: %d ops in %s, %.0f ops/sec, avg latency %dns.",
+		ops, elapsed.Round(time.Millisecond), float64(ops)/elapsed.Seconds(), avg.Nanoseconds()))
+}
+
+func runPipeBenchmark(d time.Duration) (int, time.Duration, error) { // echoes 64-byte messages over a net.Pipe for d
+	client, server := net.Pipe()
+	defer client.Close()
+
+	go func() { // the echo side: copy everything back until the client end closes
+		defer server.Close()
+		io.Copy(server, server)
+	}()
+
+	msg := bytes.Repeat([]byte("x"), benchmarkMsgSize)
+	reply := make([]byte, benchmarkMsgSize)
+	ops := 0
+	start := time.Now()
+	deadline := start.Add(d)
+	for time.Now().Before(deadline) {
+		if _, err := client.Write(msg); err != nil {
+			return ops, time.Since(start), err
+		}
+		if _, err := io.ReadFull(client, reply); err != nil {
+			return ops, time.Since(start), err
+		}
+		ops++
+	}
+	return ops, time.Since(start), nil
+}
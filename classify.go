@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// classifiers are tried in order and the first match wins, so the narrower
+// types come first: "123" is an integer rather than a float or JSON, and
+// "cafe" is hex rather than base64.
+var classifiers = []struct {
+	name  string
+	match func(string) bool
+}{
+	{"integer", func(s string) bool { _, err := strconv.ParseInt(s, 10, 64); return err == nil }},
+	{"float", isFiniteFloat},
+	{"boolean", func(s string) bool { return strings.EqualFold(s, "true") || strings.EqualFold(s, "false") }},
+	{"uuid", uuidPattern.MatchString},
+	{"ipv4", func(s string) bool { ip := net.ParseIP(s); return ip != nil && ip.To4() != nil }},
+	{"ipv6", func(s string) bool { ip := net.ParseIP(s); return ip != nil && ip.To4() == nil }},
+	{"json", func(s string) bool { return json.Valid([]byte(s)) }},
+	{"hex", func(s string) bool { _, err := hex.DecodeString(s); return err == nil }},
+	{"base64", looksLikeBase64},
+	{"url", func(s string) bool {
+		u, err := url.ParseRequestURI(s)
+		return err == nil && u.Scheme != "" && u.Host != ""
+	}},
+}
+
+func isFiniteFloat(s string) bool { // ParseFloat also takes "NaN", "Inf" and Go's 1_000, which aren't floats here
+	f, err := strconv.ParseFloat(s, 64)
+	return err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) && !strings.Contains(s, "_")
+}
+
+// looksLikeBase64 needs more than a clean decode: any run of letters whose
+// length is a multiple of 4 decodes, so "test" or "password" would count.
+// Real base64 of more than a few bytes almost always has padding, a digit,
+// + or /, so one of those is required.
+func looksLikeBase64(s string) bool {
+	if _, err := base64.StdEncoding.DecodeString(s); err != nil {
+		return false
+	}
+	return strings.ContainsFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	})
+}
+
+func classify(s string) string { // the first matching classifier's name, or "string"
+	if s == "" {
+		return "string"
+	}
+	for _, c := range classifiers {
+		if c.match(s) {
+			return c.name
+		}
+	}
+	return "string"
+}
@@ -0,0 +1,77 @@
+package main
+
+import "testing"
+
+func TestClassify(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"", "string"},
+
+		{"0", "integer"},
+		{"-42", "integer"},
+		{"+7", "integer"},
+		{"9223372036854775807", "integer"},
+		{"9223372036854775808", "float"}, // one past int64, still a number
+		{"-9223372036854775809", "float"},
+		{"12a", "string"}, // odd length, so not hex either
+		{"1_000", "string"},
+
+		{"3.14", "float"},
+		{"-0.5", "float"},
+		{"1e5", "float"},
+		{"1e400", "json"}, // too big for a float64, but still a JSON number
+		{"NaN", "string"},
+		{"Inf", "string"},
+		{"Infinity", "string"},
+
+		{"true", "boolean"},
+		{"FALSE", "boolean"},
+		{"t", "string"},
+		{"F", "string"},
+		{"yes", "string"},
+
+		{"550e8400-e29b-41d4-a716-446655440000", "uuid"},
+		{"550E8400-E29B-41D4-A716-446655440000", "uuid"},
+		{"550e8400-e29b-41d4-a716-44665544000", "string"}, // one digit short
+		{"550e8400e29b41d4a716446655440000", "hex"},       // no dashes
+
+		{"127.0.0.1", "ipv4"},
+		{"255.255.255.255", "ipv4"},
+		{"256.0.0.1", "string"},
+		{"1.2.3", "string"},
+		{"::1", "ipv6"},
+		{"2001:db8::ff00:42:8329", "ipv6"},
+		{"2001:db8:::1", "string"},
+
+		{`{"a":1}`, "json"},
+		{"[1,2,3]", "json"},
+		{`"quoted"`, "json"},
+		{"null", "json"},
+		{`{"a":1`, "string"},
+
+		{"cafe", "hex"},
+		{"DEADBEEF", "hex"},
+		{"abc", "string"}, // odd length
+
+		{"aGVsbG8=", "base64"},
+		{"aGVsbG8gd29ybGQ=", "base64"},
+		{"SGk/", "base64"},
+		{"test", "string"},
+		{"word", "string"},
+		{"password", "string"},
+		{"aGVsbG8", "string"}, // missing its padding
+
+		{"https://example.com/path?q=1", "url"},
+		{"ftp://files.example.com", "url"},
+		{"example.com", "string"},
+		{"/tmp/notes.txt", "string"},
+		{"mailto:me@example.com", "string"}, // no host
+
+		{"hello world", "string"},
+		{"hello", "string"},
+	}
+	for _, tt := range tests {
+		if got := classify(tt.in); got != tt.want {
+			t.Errorf("classify(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Package client is a small client for the echo server. When the server runs
+// with -hmac-key, the client checks the HMAC attached to every echoed line.
+package client
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+var ErrHMACMismatch = errors.New("hmac mismatch: echoed message was altered")
+
+type Client struct { // Client object, wraps a connection to the server
+	conn   net.Conn
+	reader *bufio.Reader
+	key    []byte
+}
+
+func Dial(addr string, key []byte) (*Client, error) { // connects to the server at addr
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, reader: bufio.NewReader(conn), key: key}, nil
+}
+
+func (c *Client) Echo(message string) (string, error) { // sends a message and returns the verified echo
+	if _, err := c.conn.Write([]byte(message + "\n")); err != nil {
+		return "", err
+	}
+
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+
+	if len(c.key) == 0 {
+		return line, nil
+	}
+	return Verify(line, c.key)
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func Verify(line string, key []byte) (string, error) { // checks "<message> HMAC=<hex>" and returns the message
+	idx := strings.LastIndex(line, " HMAC=")
+	if idx < 0 {
+		return "", fmt.Errorf("missing HMAC in response: %q", line)
+	}
+	message, tag := line[:idx], line[idx+len(" HMAC="):]
+
+	got, err := hex.DecodeString(tag)
+	if err != nil {
+		return "", fmt.Errorf("malformed HMAC: %v", err)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return "", ErrHMACMismatch
+	}
+	return message, nil
+}
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var testKey = []byte("secret")
+
+func sign(message string) string { // what the server sends for message with -hmac-key secret
+	mac := hmac.New(sha256.New, testKey)
+	mac.Write([]byte(message))
+	return message + " HMAC=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerify(t *testing.T) {
+	good := sign("hello world")
+	tag := good[strings.LastIndex(good, "=")+1:]
+	flipped := "0" + tag[1:]
+	if tag[0] == '0' {
+		flipped = "1" + tag[1:]
+	}
+
+	tests := []struct {
+		name     string
+		line     string
+		want     string
+		mismatch bool // expect ErrHMACMismatch rather than some other error
+		fails    bool
+	}{
+		{name: "good", line: good, want: "hello world"},
+		{name: "empty message", line: sign(""), want: ""},
+		{name: "message containing HMAC=", line: sign("a HMAC=b"), want: "a HMAC=b"},
+		{name: "tampered message", line: "hello World HMAC=" + tag, mismatch: true, fails: true},
+		{name: "message with a byte added", line: "hello world! HMAC=" + tag, mismatch: true, fails: true},
+		{name: "tampered tag", line: "hello world HMAC=" + flipped, mismatch: true, fails: true},
+		{name: "truncated tag", line: "hello world HMAC=" + tag[:len(tag)-2], mismatch: true, fails: true},
+		{name: "wrong key", line: "hello world HMAC=" + strings.Repeat("00", sha256.Size), mismatch: true, fails: true},
+		{name: "missing HMAC", line: "hello world", fails: true},
+		{name: "non-hex tag", line: "hello world HMAC=zz" + tag[2:], fails: true},
+		{name: "odd-length tag", line: "hello world HMAC=abc", fails: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Verify(tt.line, testKey)
+			if !tt.fails {
+				if err != nil || got != tt.want {
+					t.Fatalf("Verify(%q) = %q, %v; want %q, nil", tt.line, got, err, tt.want)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Verify(%q) = %q, nil; want an error", tt.line, got)
+			}
+			if errors.Is(err, ErrHMACMismatch) != tt.mismatch {
+				t.Fatalf("Verify(%q) error = %v; ErrHMACMismatch expected: %v", tt.line, err, tt.mismatch)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+// Command echoclient pipes stdin through an echo server, one line at a time,
+// and writes each reply to stdout. It exits with status 1 if the server goes
+// away before stdin is exhausted. Typing /quit ends the session locally.
+//
+//	printf 'hello\n/len héllo\n' | echoclient -addr localhost:4000
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spector-asael/echo-server/client"
+)
+
+const maxMessageSize = 1024 // must match the server
+
+const drainTimeout = 100 * time.Millisecond // how long to wait for further lines of a multi-line reply
+
+type options struct {
+	hmacKey []byte        // verify the HMAC on echoed lines when set
+	timeout time.Duration // wait this long for the first line of each reply
+}
+
+func main() {
+	addr := flag.String("addr", "localhost:4000", "Address of the echo server.")
+	useTLS := flag.Bool("tls", false, "Connect using TLS.")
+	token := flag.String("token", "", "Admin password to send with /auth after connecting.")
+	hmacKey := flag.String("hmac-key", "", "Verify echoed lines against this key (the server's -hmac-key).")
+	timeout := flag.Duration("timeout", 5*time.Second, "How long to wait for each reply.")
+	flag.Parse()
+
+	conn, err := dial(*addr, *useTLS, *timeout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "echoclient:", err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	opts := options{hmacKey: []byte(*hmacKey), timeout: *timeout}
+	replies := bufio.NewReader(conn)
+
+	if *token != "" {
+		if err := send(conn, replies, "/auth "+*token, io.Discard, opts); err != nil {
+			fmt.Fprintln(os.Stderr, "echoclient:", err)
+			os.Exit(1)
+		}
+	}
+
+	if err := pipe(conn, replies, os.Stdin, os.Stdout, opts); err != nil {
+		fmt.Fprintln(os.Stderr, "echoclient:", err)
+		os.Exit(1)
+	}
+}
+
+func dial(addr string, useTLS bool, timeout time.Duration) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	if useTLS {
+		return tls.DialWithDialer(dialer, "tcp", addr, nil)
+	}
+	return dialer.Dial("tcp", addr)
+}
+
+func pipe(conn net.Conn, replies *bufio.Reader, in io.Reader, out io.Writer, opts options) error { // sends each line and waits for its reply before the next
+	lines := bufio.NewScanner(in)
+
+	for lines.Scan() {
+		line := lines.Text()
+		if strings.TrimSpace(line) == "" {
+			continue // the server doesn't answer empty messages
+		}
+		if strings.TrimSpace(line) == "/quit" {
+			return nil // handled locally, just hang up
+		}
+		if len(line)+1 >= maxMessageSize { // the server rejects any read that fills its whole buffer
+			fmt.Fprintf(os.Stderr, "echoclient: skipping line longer than %d bytes\n", maxMessageSize-2)
+			continue
+		}
+
+		if err := send(conn, replies, line, out, opts); err != nil {
+			return err
+		}
+	}
+	return lines.Err()
+}
+
+func send(conn net.Conn, replies *bufio.Reader, line string, out io.Writer, opts options) error {
+	if _, err := conn.Write([]byte(line + "\n")); err != nil {
+		return fmt.Errorf("server closed the connection: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(opts.timeout))
+	first, err := replies.ReadString('\n')
+	if err != nil {
+		io.WriteString(out, first)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return errors.New("timed out waiting for a reply")
+		}
+		return fmt.Errorf("server closed the connection: %v", err)
+	}
+	if err := writeReply(out, first, opts); err != nil {
+		return err
+	}
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(drainTimeout))
+		more, err := replies.ReadString('\n')
+		if err != nil {
+			io.WriteString(out, more)
+			return nil // nothing more for now; a closed connection shows up on the next write or read
+		}
+		if err := writeReply(out, more, opts); err != nil {
+			return err
+		}
+	}
+}
+
+func writeReply(out io.Writer, line string, opts options) error { // checks and strips the HMAC from echoed lines
+	if len(opts.hmacKey) > 0 && strings.Contains(line, " HMAC=") {
+		message, err := client.Verify(strings.TrimRight(line, "\r\n"), opts.hmacKey)
+		if err != nil {
+			return err
+		}
+		line = message + "\n"
+	}
+	_, err := io.WriteString(out, line)
+	return err
+}
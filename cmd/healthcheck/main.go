@@ -0,0 +1,55 @@
+// Command healthcheck exits 0 if an echo server answers "hello" with
+// "Hi there!", and 1 otherwise. It is meant for a Dockerfile HEALTHCHECK:
+//
+//	HEALTHCHECK CMD ["healthcheck", "-addr", "localhost:4000"]
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const timeout = 3 * time.Second // for the whole check, connect included
+
+func main() {
+	addr := flag.String("addr", "localhost:4000", "Address of the echo server.")
+	flag.Parse()
+
+	if err := check(*addr); err != nil {
+		fmt.Fprintln(os.Stderr, "healthcheck:", err)
+		os.Exit(1)
+	}
+}
+
+func check(addr string) error {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if _, err := conn.Write([]byte("hello\n")); err != nil {
+		return err
+	}
+	replies := bufio.NewReader(conn)
+	var last string
+	for { // skip any -banner-text lines sent before the reply
+		reply, err := replies.ReadString('\n')
+		if err != nil {
+			if last != "" {
+				return fmt.Errorf("unexpected reply from %s: %q", addr, last)
+			}
+			return fmt.Errorf("no reply from %s: %v", addr, err)
+		}
+		if strings.Contains(reply, "Hi there!") {
+			return nil
+		}
+		last = strings.TrimSpace(reply)
+	}
+}
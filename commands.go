@@ -0,0 +1,1117 @@
+package main
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+var commandHelp = []string{ // one line per command, shown by /help
+	"/help                      Show this list of commands",
+	"/quit                      Disconnect (same as typing bye)",
+	"/base64 encode <text>      Base64-encode text",
+	"/base64 decode <b64>       Decode a base64 string",
+	"/hex encode <text>         Hex-encode text",
+	"/hex decode <hex>          Decode a hex string",
+	"/hash <algo> <message>     Hash a message (md5, sha1, sha256, sha512; md5 is for compatibility only)",
+	"/md5 <message>             Same as /hash md5",
+	"/sha256 <message>          Same as /hash sha256",
+	"/len <message>             Show a message's length in bytes, runes and words",
+	"/auth <password>           Authenticate as an admin",
+	"/repeat <n> <interval> <message>  Send a message n times, interval apart (admin)",
+	"/sleep <duration>          Wait before replying (max 5s, 30s for admins)",
+	"/reverse <message>         Echo a message with its characters reversed",
+	"/rot13 <message>           Echo a message with rot13 applied",
+	"/format <mode>             Transform all echoes: upper, lower, rot13, leet or off",
+	"/normalize on|off          Collapse runs of whitespace in echoes to a single space",
+	"/rekey                     Replace this session's HMAC key with a random one (admin)",
+	"/time [tz]                 Show the current time, optionally in an IANA timezone",
+	"/timezones [page]          List timezone names, 20 per page (also /time tz list)",
+	"/timezones search <prefix> [page]  List timezone names starting with prefix, 20 per page",
+	"/echo <message>            Echo a message",
+	"/echo raw <message>        Echo a message with no /format or other transformation (admin)",
+	"/echo repeat <n> <message> Echo a message n times back-to-back, max 1000 (admin)",
+	"/echo count [message]      How often you sent a message, or your top 5 messages",
+	"/echo json <message>       Echo a message with its metadata as a JSON object",
+	"/echo json pretty <json>   Pretty-print a JSON document with two-space indents",
+	"/echo json minify <json>   Strip the whitespace from a JSON document",
+	"/echo template <template>  Echo a Go text/template; fields: .Now .Remote .Nick .Seq (template count) .MsgCount",
+	"/echo lorem [n]            Send n paragraphs of Lorem Ipsum filler text, max 5 (default 1)",
+	"/timer                     Show how long ago you connected",
+	"/timer reset               Restart the timer and clear its laps (admin)",
+	"/timer lap <label>         Record a lap and show the time since the previous one",
+	"/timer laps                List recorded laps",
+	"/echo stress <n> <size>    Send n messages of size bytes as fast as possible and time it, max 10000 x 1024 (admin)",
+	"/echo scramble [seed <n>] <message>  Echo a message with its characters shuffled, reproducibly with a seed",
+	"/echo upper <message>      Echo a message in upper case, without changing /format",
+	"/echo lower <message>      Echo a message in lower case, without changing /format",
+	"/echo leet <message>       Echo a message in leet speak (a=4 e=3 i=1 o=0 s=5 t=7)",
+	"/echo unleet <message>     Turn leet speak digits back into letters",
+	"/diagnostics [time]        Run server self-tests; pass your time as RFC3339 to check clock skew",
+	"/benchmark [duration]      Time 64-byte echoes over an in-memory pipe, default 5s, max 30s",
+	"/netinfo                   Show addresses, TLS and socket options for this connection",
+	"/echo wordcount <message>  Count the words, characters and lines in a message (also /wc)",
+	"/echo palindrome <message> Check whether a message reads the same backwards, ignoring case and spaces",
+	"/echo anagram <w1> <w2>    Check whether two words are anagrams, ignoring case",
+	"/echo uuid [n]             Generate n random (version 4) UUIDs, max 10 (default 1)",
+	"/echo timestamp            Show the time as Unix nanoseconds and RFC3339Nano",
+	"/echo timestamp since <ns> Show the time elapsed since a Unix nanosecond timestamp, e.g. for RTT",
+	"/echo ip|port|addr         Show your IP address, port or both as the server sees them",
+	"/echo size                 Show the largest message the server accepts",
+	"/echo hostname             Show the server's hostname",
+	"/echo env <NAME>           Show an environment variable listed in -env-whitelist (admin)",
+	"/echo cpu                  Show goroutine and memory stats; once per 5s as it pauses the server (admin)",
+	"/echo gc                   Force a garbage collection, at most once per 30s server-wide (admin)",
+	"/echo rand int [min] [max] Random integer in [min, max] (also: rand float, rand string <n>, rand uuid)",
+	"/echo repeat-char <c> <n>  Send one character n times, up to the message size limit",
+	"/echo null [n]             Send n NUL bytes and a newline (default 1)",
+	"/echo crlf|cr|bell         Send \\r\\n, a bare \\r, or BEL and a newline",
+	"/echo type <message>       Classify a message: integer, float, boolean, uuid, ipv4, ipv6, json, hex, base64, url or string",
+	"/echo trim <message>       Show a message quoted, trimmed, and how many bytes were trimmed; quote it to keep the whitespace",
+	"/echo contains [-i] <s> -- <sub>  Reply true if s contains sub, -i ignores case",
+	"/echo hasprefix <s> -- <prefix>   Reply true if s starts with prefix",
+	"/echo hassuffix <s> -- <suffix>   Reply true if s ends with suffix",
+	"/echo split <c> <string>   Split a string on the character c, one numbered token per line, max 20",
+	"/echo split-space <string> Split a string on whitespace, one numbered token per line, max 20",
+	"/echo join <d> <t1> [t2 ...]  Join tokens with the delimiter d; write \\ before a space to keep it in a token",
+	"/echo words unique|sort|count <message>  Remove repeated words, sort words ignoring case, or count unique words",
+	"/echo lines count <message>  Count the lines in a multi-line message",
+	"/echo lines <n> <message>  Show line n of a multi-line message",
+	"/echo csv <csv line>       Parse a CSV line and echo it back with every field quoted",
+	"/echo url encode [query|path] <string>  URL-encode a string; query (the default) turns spaces into +, path into %20",
+	"/echo url decode <encoded> Decode a URL-encoded query string",
+	"/echo xml validate <xml>   Check that an XML document is well-formed",
+	"/echo xml escape <text>    Replace &, <, >, quotes and control characters with XML entities",
+	"/count                     Show message and byte counts for this session",
+	"/stats [reset]             Show server statistics; reset clears the connection watermark (admin)",
+	"/history [term]            List your last 10 commands",
+	"/!!                        Run your last command again",
+	"/!<n>                      Run command n from /history again",
+	"/setvar <name> <value>     Store a value for this session",
+	"/getvar <name>             Show a stored value",
+	"/listvars                  List all stored values",
+	"/prefix <string>           Change the prefix added to echoes; quote it to keep spaces (admin)",
+	"/suffix <string>           Change the suffix added to echoes; quote it to keep spaces (admin)",
+}
+
+// Replies sent by handleClientMessage itself; the command handlers keep
+// their own text next to the code that produces it.
+const (
+	msgHello          = "Hi there!"
+	msgGoodbye        = "Goodbye!"
+	msgNoSuchHistory  = "No such command in history."
+	msgAuthFailed     = "Authentication failed."
+	msgAuthOK         = "Authenticated as admin."
+	msgNormalizeOn    = "Whitespace normalization on."
+	msgNormalizeOff   = "Whitespace normalization off."
+	msgUsageNormalize = "Usage: /normalize on|off"
+	msgLen            = "bytes=%d runes=%d words=%d"
+	msgVarNotSet      = "Variable %s not set."
+	msgPrefixSet      = "Echo prefix set to %q."
+	msgSuffixSet      = "Echo suffix set to %q."
+	msgAffixTooLong   = "Prefix and suffix together cannot be more than %d bytes."
+	msgCount          = "Messages sent: %d, Bytes sent: %d, Bytes received: %d, Session duration: %s."
+	msgUnknownCommand = "Unknown command: %s. Type /help for a list of commands."
+	msgAdminOnly      = "Permission denied: admin only. Use /auth <password> first."
+	msgDisabled       = "Command not available."
+)
+
+var commandAliases = map[string]string{ // shorthand -> the command it stands for, arguments are appended
+	"/md5":    "/hash md5",
+	"/sha256": "/hash sha256",
+	"/wc":     "/echo wordcount",
+}
+
+func handleClientMessage(session *clientSession, message string) error { // echo a message, or run it if it is a /command
+	switch strings.ToLower(message) {
+	case "hello":
+		return writeLine(session, msgHello)
+	case "bye", "/quit":
+		writeLine(session, msgGoodbye)
+		return &DisconnectError{Reason: message}
+	}
+
+	if !strings.HasPrefix(message, "/") {
+		session.countMessage(message)
+		return echoMessage(session, message)
+	}
+
+	if strings.HasPrefix(message, "/!") { // history recall is resolved before it can be recorded
+		recalled, ok := session.recallCommand(message)
+		if !ok {
+			return writeLine(session, msgNoSuchHistory)
+		}
+		return handleClientMessage(session, recalled)
+	}
+	session.recordCommand(message)
+
+	cmd, args := splitArg(message)
+	typed := cmd
+	if canonical, ok := commandAliases[cmd]; ok {
+		cmd, args = splitArg(strings.TrimSpace(canonical + " " + args))
+	}
+	for _, name := range []string{typed, cmd} { // disabling a command disables its aliases, and an alias can be disabled alone
+		if _, disabled := cfg.disabledCommands[strings.TrimPrefix(name, "/")]; disabled {
+			return writeLine(session, msgDisabled)
+		}
+	}
+	switch cmd {
+	case "/help":
+		return writeLine(session, strings.Join(commandHelp, "\n"))
+	case "/base64":
+		return handleBase64(session, args)
+	case "/hex":
+		return handleHex(session, args)
+	case "/hash":
+		return handleHash(session, args)
+	case "/len":
+		return writeLine(session, fmt.Sprintf(msgLen, len(args), utf8.RuneCountInString(args), len(strings.Fields(args))))
+	case "/auth":
+		if !session.authenticate(args) {
+			return writeLine(session, msgAuthFailed)
+		}
+		return writeLine(session, msgAuthOK)
+	case "/repeat":
+		return handleRepeat(session, args)
+	case "/sleep":
+		return handleSleep(session, args)
+	case "/reverse":
+		return writeLine(session, reverseRunes(args))
+	case "/rot13":
+		return writeLine(session, rot13(args))
+	case "/format":
+		return handleFormat(session, args)
+	case "/normalize":
+		switch strings.ToLower(args) {
+		case "on":
+			session.normalize = true
+			return writeLine(session, msgNormalizeOn)
+		case "off":
+			session.normalize = false
+			return writeLine(session, msgNormalizeOff)
+		default:
+			return writeLine(session, msgUsageNormalize)
+		}
+	case "/history":
+		return handleHistory(session, args)
+	case "/setvar":
+		return handleSetVar(session, args)
+	case "/getvar":
+		if args == "" {
+			return writeLine(session, "Usage: /getvar <name>")
+		}
+		value, ok := session.vars[args]
+		if !ok {
+			return writeLine(session, fmt.Sprintf(msgVarNotSet, args))
+		}
+		return writeLine(session, value)
+	case "/listvars":
+		return handleListVars(session)
+	case "/prefix":
+		if !requireAdmin(session) {
+			return nil
+		}
+		prefix := unquoteArg(args)
+		if len(prefix)+len(echoSuffix.Load().(string)) > maxAffixSize {
+			return writeLine(session, fmt.Sprintf(msgAffixTooLong, maxAffixSize))
+		}
+		echoPrefix.Store(prefix)
+		auditAction(session, "config-change", "echo-prefix="+prefix, true)
+		return writeLine(session, fmt.Sprintf(msgPrefixSet, prefix))
+	case "/suffix":
+		if !requireAdmin(session) {
+			return nil
+		}
+		suffix := unquoteArg(args)
+		if len(echoPrefix.Load().(string))+len(suffix) > maxAffixSize {
+			return writeLine(session, fmt.Sprintf(msgAffixTooLong, maxAffixSize))
+		}
+		echoSuffix.Store(suffix)
+		auditAction(session, "config-change", "echo-suffix="+suffix, true)
+		return writeLine(session, fmt.Sprintf(msgSuffixSet, suffix))
+	case "/stats":
+		return handleStats(session, args)
+	case "/rekey":
+		return handleRekey(session)
+	case "/time":
+		if args == "tz list" || strings.HasPrefix(args, "tz list ") {
+			return handleTimezones(session, strings.TrimSpace(strings.TrimPrefix(args, "tz list")))
+		}
+		return handleTime(session, args)
+	case "/timezones":
+		return handleTimezones(session, args)
+	case "/echo":
+		return handleEchoCommand(session, args)
+	case "/benchmark":
+		return handleBenchmark(session, args)
+	case "/netinfo":
+		return handleNetinfo(session)
+	case "/diagnostics":
+		return handleDiagnostics(session, args)
+	case "/timer":
+		return handleTimer(session, args)
+	case "/count":
+		return writeLine(session, fmt.Sprintf(msgCount,
+			session.msgCount.Load(), session.bytesIn.Load(), session.bytesOut.Load(), time.Since(session.connectedAt).Round(time.Second)))
+	default:
+		return writeLine(session, fmt.Sprintf(msgUnknownCommand, cmd))
+	}
+}
+
+func handleTimer(session *clientSession, args string) error {
+	sub, label := splitArg(args)
+	switch sub {
+	case "":
+		return writeLine(session, fmt.Sprintf("Connected %s ago.", time.Since(session.timerStart).Round(time.Millisecond)))
+	case "reset":
+		if !requireAdmin(session) {
+			return nil
+		}
+		session.timerStart = time.Now()
+		session.laps = nil
+		return writeLine(session, "Timer reset.")
+	case "lap":
+		if label == "" {
+			return writeLine(session, "Usage: /timer lap <label>")
+		}
+		last := session.timerStart
+		if len(session.laps) > 0 {
+			last = session.laps[len(session.laps)-1].at
+		}
+		now := time.Now()
+		lap := lapEntry{label: label, at: now, split: now.Sub(last)}
+		session.laps = append(session.laps, lap)
+		return writeLine(session, fmt.Sprintf("Lap '%s': %s.", label, lap.split.Round(time.Millisecond)))
+	case "laps":
+		if len(session.laps) == 0 {
+			return writeLine(session, "No laps recorded.")
+		}
+		lines := make([]string, len(session.laps))
+		for i, lap := range session.laps {
+			lines[i] = fmt.Sprintf("%d. %s: %s (total %s)", i+1, lap.label,
+				lap.split.Round(time.Millisecond), lap.at.Sub(session.timerStart).Round(time.Millisecond))
+		}
+		return writeLine(session, strings.Join(lines, "\n"))
+	default:
+		return writeLine(session, "Usage: /timer [reset | lap <label> | laps]")
+	}
+}
+
+type DisconnectError struct { // returned when a session ends on purpose, by the client or a limit, so it isn't logged as an error
+	Reason string
+}
+
+func (e *DisconnectError) Error() string {
+	return fmt.Sprintf("client disconnected (%s)", e.Reason)
+}
+
+var (
+	echoPrefix atomic.Value // string, set from -echo-prefix and changed with /prefix
+	echoSuffix atomic.Value // string, set from -echo-suffix and changed with /suffix
+)
+
+const echoHeadroom = 64 // bytes allowed past maxMessageSize for the prefix and suffix
+
+// maxAffixSize caps the prefix and suffix together, so that an echo always
+// keeps at least half a message of its own text within the headroom.
+const maxAffixSize = maxMessageSize/2 + echoHeadroom
+
+func echoMessage(session *clientSession, message string) error { // writes a plain message back to the client
+	prefix, suffix := echoPrefix.Load().(string), echoSuffix.Load().(string)
+	if session.normalize {
+		message = normalizeWhitespace(message)
+	}
+	body := applyFormat(session.format, message)
+
+	limit := maxMessageSize + echoHeadroom - 1 // leave room for the newline
+	if len(prefix)+len(body)+len(suffix) > limit {
+		keep := max(limit-len(prefix)-len(suffix)-len("..."), 0)
+		body = truncateUTF8(body, keep) + "..."
+	}
+	return writeLine(session, signMessage(session.hmacKey, prefix+body+suffix))
+}
+
+func truncateUTF8(s string, n int) string { // cuts s to at most n bytes without splitting a rune
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+func handleEchoCommand(session *clientSession, args string) error { // dispatches the /echo subcommands
+	sub, rest := splitArg(args)
+	switch sub {
+	case "raw":
+		if !requireAdmin(session) {
+			return nil
+		}
+		if len(rest)+1 > maxMessageSize {
+			return writeLine(session, fmt.Sprintf("Message cannot be more than %d bytes.", maxMessageSize))
+		}
+		return writeLine(session, rest)
+	case "repeat":
+		return handleEchoRepeat(session, rest)
+	case "count":
+		return handleEchoCount(session, rest)
+	case "json":
+		if mode, doc := splitArg(rest); mode == "pretty" || mode == "minify" {
+			return handleEchoJSONFormat(session, mode, doc)
+		}
+		return handleEchoJSON(session, rest)
+	case "template":
+		session.templateSeq++
+		out, err := renderTemplate(rest, templateData{
+			Now:      time.Now(),
+			Remote:   session.conn.RemoteAddr().String(),
+			Seq:      session.templateSeq,
+			MsgCount: session.msgCount.Load(),
+		})
+		if err != nil {
+			return writeLine(session, fmt.Sprintf("Template error: %v", err))
+		}
+		return writeLine(session, out)
+	case "lorem":
+		return handleEchoLorem(session, rest)
+	case "stress":
+		return handleEchoStress(session, rest)
+	case "scramble":
+		return handleEchoScramble(session, rest)
+	case "wordcount":
+		return writeLine(session, wordCount(rest))
+	case "palindrome":
+		if rest == "" {
+			return writeLine(session, "Usage: /echo palindrome <message>")
+		}
+		if isPalindrome(rest) {
+			return writeLine(session, "yes")
+		}
+		return writeLine(session, fmt.Sprintf("no (%s)", reverseRunes(rest)))
+	case "anagram":
+		first, second := splitArg(rest)
+		if first == "" || second == "" {
+			return writeLine(session, "Usage: /echo anagram <word1> <word2>.")
+		}
+		if isAnagram(first, second) {
+			return writeLine(session, "yes")
+		}
+		return writeLine(session, "no")
+	case "uuid":
+		return handleEchoUUID(session, rest)
+	case "timestamp":
+		return handleEchoTimestamp(session, rest)
+	case "ip", "port", "addr":
+		addr := session.conn.RemoteAddr().String()
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || sub == "addr" {
+			return writeLine(session, addr)
+		}
+		if sub == "port" {
+			return writeLine(session, port)
+		}
+		return writeLine(session, host)
+	case "size":
+		return writeLine(session, fmt.Sprintf("max_message_size=%d", maxMessageSize))
+	case "hostname":
+		return writeLine(session, "hostname="+serverHostname)
+	case "env":
+		return handleEchoEnv(session, rest)
+	case "rand":
+		return handleEchoRand(session, rest)
+	case "repeat-char":
+		return handleEchoRepeatChar(session, rest)
+	case "null":
+		n := 1
+		if rest != "" {
+			var err error
+			if n, err = strconv.Atoi(rest); err != nil || n < 1 || n > maxMessageSize-1 {
+				return writeLine(session, fmt.Sprintf("Usage: /echo null [n], n between 1 and %d", maxMessageSize-1))
+			}
+		}
+		_, err := session.Write(append(make([]byte, n), '\n'))
+		return err
+	case "crlf", "cr", "bell":
+		_, err := io.WriteString(session, controlReplies[sub]) // raw bytes, nothing added
+		return err
+	case "type":
+		return writeLine(session, "type="+classify(rest))
+	case "trim":
+		original := unquoteArg(rest) // the line itself was trimmed on arrival, so only quoted whitespace gets this far
+		trimmed := strings.TrimSpace(original)
+		return writeLine(session, fmt.Sprintf("%q\n%s\nTrimmed: %d bytes.", original, trimmed, len(original)-len(trimmed)))
+	case "contains", "hasprefix", "hassuffix":
+		return handleEchoContains(session, sub, rest)
+	case "split", "split-space":
+		return handleEchoSplit(session, sub, rest)
+	case "join":
+		return handleEchoJoin(session, rest)
+	case "words":
+		return handleEchoWords(session, rest)
+	case "lines":
+		return handleEchoLines(session, rest)
+	case "csv":
+		return handleEchoCSV(session, rest)
+	case "url":
+		return handleEchoURL(session, rest)
+	case "xml":
+		return handleEchoXML(session, rest)
+	case "cpu":
+		return handleEchoCPU(session)
+	case "gc":
+		return handleEchoGC(session)
+	case "upper":
+		return writeLine(session, strings.ToUpper(rest))
+	case "lower":
+		return writeLine(session, strings.ToLower(rest))
+	case "leet":
+		return writeLine(session, leet(rest))
+	case "unleet":
+		return writeLine(session, unleet(rest))
+	default:
+		return echoMessage(session, args)
+	}
+}
+
+const argDelimiter = " -- " // separates two free-text arguments
+
+func handleEchoContains(session *clientSession, sub, args string) error { // contains, hasprefix and hassuffix
+	foldCase := false
+	if sub == "contains" {
+		if opt, rest := splitArg(args); opt == "-i" {
+			foldCase, args = true, rest
+		}
+	}
+	s, arg, found := strings.Cut(args, argDelimiter) // the first delimiter wins, later ones belong to the argument
+	if !found {
+		return writeLine(session, fmt.Sprintf("Usage: /echo %s <s>%s<arg>", sub, argDelimiter))
+	}
+	if foldCase {
+		s, arg = strings.ToLower(s), strings.ToLower(arg)
+	}
+
+	var result bool
+	switch sub {
+	case "contains":
+		result = strings.Contains(s, arg)
+	case "hasprefix":
+		result = strings.HasPrefix(s, arg)
+	case "hassuffix":
+		result = strings.HasSuffix(s, arg)
+	}
+	return writeLine(session, strconv.FormatBool(result))
+}
+
+const maxEchoRepeat = 1000
+
+func handleEchoRepeat(session *clientSession, args string) error {
+	if !requireAdmin(session) {
+		return nil
+	}
+
+	countStr, message := splitArg(args)
+	count, err := strconv.Atoi(countStr)
+	if err != nil || message == "" {
+		return writeLine(session, "Usage: /echo repeat <n> <message>")
+	}
+	if count < 1 || count > maxEchoRepeat {
+		return writeLine(session, fmt.Sprintf("n must be between 1 and %d.", maxEchoRepeat))
+	}
+
+	w := bufio.NewWriter(session) // one flush at the end keeps it to a handful of syscalls
+	line := signMessage(session.hmacKey, message) + "\n"
+	for i := 0; i < count; i++ {
+		w.WriteString(line)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	return writeLine(session, fmt.Sprintf("Done. Sent %d messages, %d bytes.", count, count*len(line)))
+}
+
+// wordCount describes a message for /echo wordcount. handleEcho hands over
+// everything one read returned, so a message can hold several lines.
+func wordCount(message string) string {
+	return fmt.Sprintf("words=%d chars=%d lines=%d", len(strings.Fields(message)), utf8.RuneCountInString(message), len(splitLines(message)))
+}
+
+const maxUUIDs = 10
+
+func handleEchoUUID(session *clientSession, args string) error {
+	n := 1
+	if args != "" {
+		var err error
+		if n, err = strconv.Atoi(args); err != nil || n < 1 || n > maxUUIDs {
+			return writeLine(session, fmt.Sprintf("Usage: /echo uuid [n], n between 1 and %d", maxUUIDs))
+		}
+	}
+
+	uuids := make([]string, n)
+	for i := range uuids {
+		uuids[i] = newUUID()
+	}
+	return writeLine(session, strings.Join(uuids, "\n"))
+}
+
+func newUUID() string { // a random RFC 4122 version 4 UUID
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	h := hex.EncodeToString(b[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+const maxTimestampAhead = time.Hour // further ahead than this is a mistake, not clock skew
+
+func handleEchoTimestamp(session *clientSession, args string) error {
+	now := time.Now()
+	if args == "" {
+		return writeLine(session, fmt.Sprintf("%d %s", now.UnixNano(), now.Format(time.RFC3339Nano)))
+	}
+
+	sub, tStr := splitArg(args)
+	if sub != "since" || tStr == "" {
+		return writeLine(session, "Usage: /echo timestamp [since <unix-nanoseconds>]")
+	}
+	ns, err := strconv.ParseInt(tStr, 10, 64)
+	if err != nil || ns < 0 {
+		return writeLine(session, fmt.Sprintf("Invalid timestamp %q: must be a non-negative integer of Unix nanoseconds.", tStr))
+	}
+	t := time.Unix(0, ns)
+	if t.After(now.Add(maxTimestampAhead)) {
+		return writeLine(session, fmt.Sprintf("Invalid timestamp %q: more than %s in the future.", tStr, maxTimestampAhead))
+	}
+	return writeLine(session, now.Sub(t).String())
+}
+
+func handleEchoEnv(session *clientSession, name string) error {
+	if !requireAdmin(session) {
+		return nil
+	}
+	if name == "" {
+		return writeLine(session, "Usage: /echo env <NAME>")
+	}
+	if _, ok := cfg.envWhitelist[name]; !ok {
+		auditAction(session, "env-read", name, false)
+		return writeLine(session, "Access denied.")
+	}
+	auditAction(session, "env-read", name, true)
+
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		value = "(not set)"
+	}
+	return writeLine(session, name+"="+value)
+}
+
+func handleEchoRepeatChar(session *clientSession, args string) error {
+	char, nStr := splitArg(args)
+	n, err := strconv.Atoi(nStr)
+	if err != nil || n < 1 || utf8.RuneCountInString(char) != 1 {
+		return writeLine(session, "Usage: /echo repeat-char <char> <n>, where char is a single character")
+	}
+	if limit := (maxMessageSize - 1) / len(char); n > limit { // multi-byte characters fit fewer times
+		return writeLine(session, fmt.Sprintf("n cannot be more than %d for %q, or the reply would exceed %d bytes.", limit, char, maxMessageSize))
+	}
+	return writeLine(session, strings.Repeat(char, n))
+}
+
+var controlReplies = map[string]string{ // for testing how clients parse line endings and control bytes
+	"crlf": "\r\n",
+	"cr":   "\r",
+	"bell": "\x07\n",
+}
+
+func handleEchoLorem(session *clientSession, args string) error {
+	n := 1
+	if args != "" {
+		var err error
+		if n, err = strconv.Atoi(args); err != nil || n < 1 || n > len(loremParagraphs) {
+			return writeLine(session, fmt.Sprintf("Usage: /echo lorem [n], n between 1 and %d", len(loremParagraphs)))
+		}
+	}
+
+	var b strings.Builder
+	for _, p := range loremParagraphs[:n] {
+		if len(p)+1 > maxMessageSize {
+			p = truncateUTF8(p, maxMessageSize-1-len("...")) + "..."
+		}
+		b.WriteString(p + "\n\n") // a blank line after each paragraph
+	}
+	_, err := io.WriteString(session, b.String())
+	return err
+}
+
+const (
+	maxStressMessages = 10000
+	maxStressSize     = maxMessageSize
+)
+
+func handleEchoStress(session *clientSession, args string) error { // runs on the connection goroutine, so the client gets nothing else until it's done
+	if !requireAdmin(session) {
+		return nil
+	}
+
+	nStr, sizeStr := splitArg(args)
+	n, err1 := strconv.Atoi(nStr)
+	size, err2 := strconv.Atoi(sizeStr)
+	if err1 != nil || err2 != nil {
+		return writeLine(session, "Usage: /echo stress <n> <size>")
+	}
+	if n < 1 || n > maxStressMessages || size < 1 || size > maxStressSize {
+		return writeLine(session, fmt.Sprintf("n must be between 1 and %d and size between 1 and %d.", maxStressMessages, maxStressSize))
+	}
+
+	line := []byte(strings.Repeat("X", size) + "\n")
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		if _, err := session.Write(line); err != nil {
+			return err
+		}
+	}
+	elapsed := time.Since(start)
+
+	result := fmt.Sprintf("Stress test: %d messages, %d bytes, %s, %.0f msg/s.",
+		n, n*len(line), elapsed.Round(time.Microsecond), float64(n)/elapsed.Seconds())
+	session.log(result)
+	return writeLine(session, result)
+}
+
+func handleEchoScramble(session *clientSession, args string) error {
+	var seed int64
+	if first, rest := splitArg(args); first == "seed" {
+		seedStr, message := splitArg(rest)
+		n, err := strconv.ParseInt(seedStr, 10, 64)
+		if err != nil || message == "" {
+			return writeLine(session, "Usage: /echo scramble [seed <n>] <message>")
+		}
+		seed, args = n, message
+	} else {
+		seed = nextScrambleSeed()
+	}
+	if args == "" {
+		return writeLine(session, "Usage: /echo scramble [seed <n>] <message>")
+	}
+
+	session.log(fmt.Sprintf("scramble seed=%d", seed)) // enough to reproduce the output with /echo scramble seed
+	return writeLine(session, scramble(args, seed))
+}
+
+type echoJSON struct {
+	Message   string `json:"message"`
+	Length    int    `json:"length"`
+	Timestamp string `json:"timestamp"`
+	SessionID string `json:"session_id"`
+	Seq       int64  `json:"seq"`
+}
+
+func handleEchoJSON(session *clientSession, message string) error {
+	out, err := json.Marshal(echoJSON{
+		Message:   message,
+		Length:    len(message),
+		Timestamp: time.Now().Format(time.RFC3339Nano),
+		SessionID: strconv.FormatInt(session.id, 10),
+		Seq:       session.msgCount.Load(),
+	})
+	if err != nil {
+		return writeLine(session, fmt.Sprintf("Cannot encode message as JSON: %v", err))
+	}
+	if len(out)+1 > maxMessageSize { // never send a partial object
+		return writeLine(session, fmt.Sprintf("JSON output would exceed %d bytes.", maxMessageSize))
+	}
+	return writeLine(session, string(out))
+}
+
+const topMessagesShown = 5
+
+func handleEchoCount(session *clientSession, message string) error {
+	if message != "" {
+		return writeLine(session, fmt.Sprintf("%q sent %d times.", message, session.msgFreq[message]))
+	}
+	if len(session.msgFreq) == 0 {
+		return writeLine(session, "No messages sent yet.")
+	}
+
+	messages := make([]string, 0, len(session.msgFreq))
+	for m := range session.msgFreq {
+		messages = append(messages, m)
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		a, b := messages[i], messages[j]
+		if session.msgFreq[a] != session.msgFreq[b] {
+			return session.msgFreq[a] > session.msgFreq[b]
+		}
+		return a < b
+	})
+
+	lines := make([]string, 0, topMessagesShown)
+	for i, m := range messages[:min(topMessagesShown, len(messages))] {
+		lines = append(lines, fmt.Sprintf("%d. %q: %d", i+1, m, session.msgFreq[m]))
+	}
+	return writeLine(session, strings.Join(lines, "\n"))
+}
+
+func handleBase64(session *clientSession, args string) error {
+	mode, text := splitArg(args)
+	switch mode {
+	case "encode":
+		encoded := base64.StdEncoding.EncodeToString([]byte(text))
+		if len(encoded)+1 > maxMessageSize { // check the output fits before writing
+			return writeLine(session, fmt.Sprintf("Encoded output exceeds %d bytes.", maxMessageSize))
+		}
+		return writeLine(session, encoded)
+	case "decode":
+		decoded, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return writeLine(session, fmt.Sprintf("Invalid base64: %v", err))
+		}
+		return writeLine(session, string(decoded))
+	default:
+		return writeLine(session, "Usage: /base64 encode <text> | /base64 decode <b64string>")
+	}
+}
+
+func handleHex(session *clientSession, args string) error {
+	mode, text := splitArg(args)
+	switch mode {
+	case "encode":
+		encoded := hex.EncodeToString([]byte(text))
+		if len(encoded)+1 > maxMessageSize {
+			return writeLine(session, fmt.Sprintf("Encoded output exceeds %d bytes.", maxMessageSize))
+		}
+		return writeLine(session, encoded)
+	case "decode":
+		if err := validateHex(text); err != nil {
+			return writeLine(session, fmt.Sprintf("Invalid hex: %v", err))
+		}
+		decoded, err := hex.DecodeString(text)
+		if err != nil {
+			return writeLine(session, fmt.Sprintf("Invalid hex: %v", err))
+		}
+		return writeLine(session, string(decoded))
+	default:
+		return writeLine(session, "Usage: /hex encode <text> | /hex decode <hexstring>")
+	}
+}
+
+func validateHex(s string) error { // gives a clearer error than hex.DecodeString for common mistakes
+	if len(s)%2 != 0 {
+		return fmt.Errorf("odd number of hex characters (%d)", len(s))
+	}
+	for i, c := range s {
+		isHex := (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+		if !isHex {
+			return fmt.Errorf("invalid character %q at position %d", c, i)
+		}
+	}
+	return nil
+}
+
+var hashAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New, // compatibility only, not collision resistant
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+func handleHash(session *clientSession, args string) error {
+	algo, message := splitArg(args)
+	newHash, ok := hashAlgorithms[strings.ToLower(algo)]
+	if !ok {
+		return writeLine(session, "Supported algorithms: md5, sha1, sha256, sha512.")
+	}
+
+	h := newHash()
+	h.Write([]byte(message))
+	return writeLine(session, fmt.Sprintf("%s:%s", strings.ToLower(algo), hex.EncodeToString(h.Sum(nil))))
+}
+
+const (
+	maxRepeatCount    = 100
+	maxRepeatInterval = 10 * time.Second
+)
+
+func handleRepeat(session *clientSession, args string) error {
+	if !requireAdmin(session) {
+		return nil
+	}
+
+	countStr, rest := splitArg(args)
+	intervalStr, message := splitArg(rest)
+	count, err := strconv.Atoi(countStr)
+	interval, durErr := time.ParseDuration(intervalStr)
+	if err != nil || durErr != nil || message == "" {
+		return writeLine(session, "Usage: /repeat <count> <interval> <message>")
+	}
+	if count < 1 || count > maxRepeatCount {
+		return writeLine(session, fmt.Sprintf("Count must be between 1 and %d.", maxRepeatCount))
+	}
+	if interval <= 0 || interval > maxRepeatInterval {
+		return writeLine(session, fmt.Sprintf("Interval must be greater than 0 and at most %s.", maxRepeatInterval))
+	}
+
+	auditAction(session, "repeat", message, true)
+	if err := writeLine(session, fmt.Sprintf("Repeating %d times at %s intervals.", count, interval)); err != nil {
+		return err
+	}
+
+	key := session.hmacKey // copied because /rekey may replace it while we run
+
+	go func() { // stops early if the session ends
+		timer := time.NewTimer(interval)
+		defer timer.Stop()
+		for i := 0; i < count; i++ {
+			select {
+			case <-session.ctx.Done():
+				return
+			case <-timer.C:
+			}
+			if err := writeLine(session, signMessage(key, message)); err != nil {
+				return
+			}
+			timer.Reset(interval)
+		}
+	}()
+	return nil
+}
+
+const (
+	maxSleep      = 30 * time.Second
+	maxGuestSleep = 5 * time.Second // limit for sessions that are not admin
+)
+
+func handleSleep(session *clientSession, args string) error {
+	d, err := time.ParseDuration(args)
+	if err != nil || d < 0 {
+		return writeLine(session, "Usage: /sleep <duration> (e.g. /sleep 2s)")
+	}
+
+	limit := maxGuestSleep
+	if session.isAdmin {
+		limit = maxSleep
+	}
+	if d > limit {
+		return writeLine(session, fmt.Sprintf("Sleep cannot be longer than %s.", limit))
+	}
+
+	session.conn.SetReadDeadline(time.Now().Add(maxSleep + idleTimeout)) // don't time out while sleeping
+	logDebug("Client %s sleeping for %s", session.conn.RemoteAddr(), d)
+
+	start := time.Now()
+	time.Sleep(d)
+	return writeLine(session, fmt.Sprintf("Slept for %s.", time.Since(start).Round(time.Millisecond)))
+}
+
+func handleFormat(session *clientSession, args string) error {
+	mode := strings.ToLower(args)
+	if mode == "off" || mode == "none" {
+		session.format = ""
+		return writeLine(session, "Format cleared.")
+	}
+	if _, ok := formatModes[mode]; !ok {
+		return writeLine(session, "Usage: /format upper|lower|rot13|leet|off")
+	}
+	session.format = mode
+	return writeLine(session, fmt.Sprintf("Format set to %s.", mode))
+}
+
+func handleStats(session *clientSession, args string) error {
+	switch args {
+	case "":
+		limit := "unlimited"
+		if cfg.maxMessageCount > 0 {
+			limit = strconv.FormatInt(cfg.maxMessageCount, 10)
+		}
+		return writeLine(session, stats.String()+"\nMax messages per session: "+limit)
+	case "reset":
+		if !requireAdmin(session) {
+			return nil
+		}
+		resetMaxConcurrent()
+		auditAction(session, "config-change", "stats-reset", true)
+		return writeLine(session, "Max concurrent connections watermark reset.")
+	default:
+		return writeLine(session, "Usage: /stats [reset]")
+	}
+}
+
+func handleRekey(session *clientSession) error {
+	if !requireAdmin(session) {
+		return nil
+	}
+	if len(session.hmacKey) == 0 {
+		return writeLine(session, "HMAC is not enabled. Start the server with -hmac-key.")
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		auditAction(session, "rekey", "", false)
+		return writeLine(session, "Failed to generate a new key.")
+	}
+	session.hmacKey = key
+	auditAction(session, "rekey", "", true) // never log the key itself
+	return writeLine(session, "Key rotated.")
+}
+
+func handleTime(session *clientSession, tz string) error {
+	now := time.Now()
+	if tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return writeLine(session, fmt.Sprintf("Unknown timezone %q. Try /timezones search <prefix>.", tz))
+		}
+		now = now.In(loc)
+	}
+	return writeLine(session, now.Format(time.RFC3339))
+}
+
+const timezonesPerPage = 20
+
+func handleTimezones(session *clientSession, args string) error {
+	zones := listTimezones()
+	if len(zones) == 0 {
+		return writeLine(session, "No timezone database found on this server.")
+	}
+
+	page := args
+	if mode, rest := splitArg(args); mode == "search" {
+		var prefix string
+		prefix, page = splitArg(rest)
+		if prefix == "" {
+			return writeLine(session, timezonesUsage)
+		}
+		var matches []string
+		for _, zone := range zones {
+			if strings.HasPrefix(strings.ToLower(zone), strings.ToLower(prefix)) {
+				matches = append(matches, zone)
+			}
+		}
+		if len(matches) == 0 {
+			return writeLine(session, fmt.Sprintf("No timezones start with %q.", prefix))
+		}
+		zones = matches
+	}
+	return writeTimezonePage(session, zones, page)
+}
+
+const timezonesUsage = "Usage: /timezones [page] | /timezones search <prefix> [page]"
+
+func writeTimezonePage(session *clientSession, zones []string, arg string) error { // one page of zones, the whole list is too large for a reply
+	pages := (len(zones) + timezonesPerPage - 1) / timezonesPerPage
+	page := 1
+	if arg != "" {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 || n > pages {
+			return writeLine(session, fmt.Sprintf("%s (page 1-%d)", timezonesUsage, pages))
+		}
+		page = n
+	}
+
+	start := (page - 1) * timezonesPerPage
+	end := min(start+timezonesPerPage, len(zones))
+	return writeLine(session, fmt.Sprintf("%s\nPage %d of %d.", strings.Join(zones[start:end], "\n"), page, pages))
+}
+
+const historyListSize = 10
+
+func handleHistory(session *clientSession, term string) error {
+	var lines []string
+	for i, cmd := range session.cmdHistory {
+		if term == "" || strings.Contains(cmd, term) {
+			lines = append(lines, fmt.Sprintf("%d: %s", i+1, cmd))
+		}
+	}
+	if len(lines) > historyListSize {
+		lines = lines[len(lines)-historyListSize:]
+	}
+	if len(lines) == 0 {
+		return writeLine(session, "No commands in history.")
+	}
+	return writeLine(session, strings.Join(lines, "\n"))
+}
+
+const (
+	maxSessionVars = 20
+	maxVarValueLen = 256
+)
+
+var varNamePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+
+func handleSetVar(session *clientSession, args string) error {
+	name, value := splitArg(args)
+	if name == "" || value == "" {
+		return writeLine(session, "Usage: /setvar <name> <value>")
+	}
+	if !varNamePattern.MatchString(name) {
+		return writeLine(session, "Variable names must start with a letter and contain only letters, digits and _.")
+	}
+	if len(value) > maxVarValueLen {
+		return writeLine(session, fmt.Sprintf("Value cannot be more than %d bytes.", maxVarValueLen))
+	}
+	if _, exists := session.vars[name]; !exists && len(session.vars) >= maxSessionVars {
+		return writeLine(session, fmt.Sprintf("Cannot set more than %d variables.", maxSessionVars))
+	}
+
+	session.vars[name] = value
+	return writeLine(session, fmt.Sprintf("%s set.", name))
+}
+
+func handleListVars(session *clientSession) error {
+	if len(session.vars) == 0 {
+		return writeLine(session, "No variables set.")
+	}
+
+	names := make([]string, 0, len(session.vars))
+	for name := range session.vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, len(names))
+	for i, name := range names {
+		lines[i] = fmt.Sprintf("%s=%s", name, session.vars[name])
+	}
+	return writeLine(session, strings.Join(lines, "\n"))
+}
+
+func splitArg(s string) (string, string) { // splits off the first word, returns it and the trimmed rest
+	s = strings.TrimSpace(s)
+	idx := strings.IndexFunc(s, func(r rune) bool { return r == ' ' || r == '\t' })
+	if idx < 0 {
+		return s, ""
+	}
+	return s[:idx], strings.TrimSpace(s[idx+1:])
+}
+
+func unquoteArg(s string) string { // allows "quoted" arguments so surrounding spaces survive trimming
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		return unquoted
+	}
+	return s
+}
+
+func writeLine(w io.Writer, line string) error { // writes a line to the client, adding the newline
+	_, err := w.Write([]byte(line + "\n"))
+	return err
+}
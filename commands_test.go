@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+func runCommand(t *testing.T, message string) string { // what the client sees after sending message
+	t.Helper()
+	conn := &httpConn{remote: "192.0.2.1:4321"}
+	if err := handleClientMessage(newClientSession(conn), message); err != nil {
+		t.Fatalf("%q: %v", message, err)
+	}
+	return conn.String()
+}
+
+func TestWordCount(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"empty", "", "words=0 chars=0 lines=0"},
+		{"one word", "hello", "words=1 chars=5 lines=1"},
+		{"single spaces", "a b c", "words=3 chars=5 lines=1"},
+		{"multiple spaces", "a   b    c", "words=3 chars=10 lines=1"},
+		{"tabs", "a\tb\t\tc", "words=3 chars=6 lines=1"},
+		{"no-break space", "a\u00a0b", "words=2 chars=3 lines=1"},
+		{"ideographic space", "你好\u3000世界", "words=2 chars=5 lines=1"},
+		{"em space and line separator", "a\u2003b\u2028c", "words=3 chars=5 lines=1"},
+		{"zero-width space is not whitespace", "a\u200bb", "words=1 chars=3 lines=1"},
+		{"newlines", "a b\nc d", "words=4 chars=7 lines=2"},
+		{"crlf", "a\r\nb\r\nc", "words=3 chars=7 lines=3"},
+		{"blank line", "a\n\nb", "words=2 chars=4 lines=3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wordCount(tt.in); got != tt.want {
+				t.Errorf("wordCount(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEchoPalindromeReply(t *testing.T) {
+	useTestConfig(t)
+	tests := []struct{ in, want string }{
+		{"/echo palindrome racecar", "yes\n"},
+		{"/echo palindrome Never odd or even", "yes\n"},
+		{"/echo palindrome héllo", "no (olléh)\n"},
+		{"/echo palindrome", "Usage: /echo palindrome <message>\n"},
+	}
+	for _, tt := range tests {
+		if got := runCommand(t, tt.in); got != tt.want {
+			t.Errorf("%q replied %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEchoAnagramReply(t *testing.T) {
+	useTestConfig(t)
+	tests := []struct{ in, want string }{
+		{"/echo anagram listen silent", "yes\n"},
+		{"/echo anagram listen lists", "no\n"},
+		{"/echo anagram listen", "Usage: /echo anagram <word1> <word2>.\n"},
+		{"/echo anagram", "Usage: /echo anagram <word1> <word2>.\n"},
+	}
+	for _, tt := range tests {
+		if got := runCommand(t, tt.in); got != tt.want {
+			t.Errorf("%q replied %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAliasesAndDisabledCommands(t *testing.T) {
+	useTestConfig(t)
+	if got, want := runCommand(t, "/wc a b"), runCommand(t, "/echo wordcount a b"); got != want {
+		t.Errorf("/wc replied %q, /echo wordcount %q", got, want)
+	}
+	if got, want := runCommand(t, "/md5 hi"), runCommand(t, "/hash md5 hi"); got != want {
+		t.Errorf("/md5 replied %q, /hash md5 %q", got, want)
+	}
+
+	disabled := msgDisabled + "\n"
+	tests := []struct {
+		disabled, message string
+		blocked           bool
+	}{
+		{"md5", "/md5 hi", true}, // the alias by the name the user typed
+		{"md5", "/hash md5 hi", false},
+		{"hash", "/md5 hi", true}, // the canonical command covers its aliases
+		{"hash", "/sha256 hi", true},
+		{"hash", "/hash md5 hi", true},
+		{"wc", "/wc a b", true},
+		{"wc", "/echo wordcount a b", false},
+		{"echo", "/wc a b", true},
+	}
+	for _, tt := range tests {
+		cfg.disabledCommands = map[string]struct{}{tt.disabled: {}}
+		if got := runCommand(t, tt.message); (got == disabled) != tt.blocked {
+			t.Errorf("-disabled-commands %s: %q replied %q, blocked = %v", tt.disabled, tt.message, got, tt.blocked)
+		}
+	}
+}
+
+func TestEchoIPLoopback(t *testing.T) {
+	useTestConfig(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		if conn, err := ln.Accept(); err == nil {
+			handleConnection(conn)
+		}
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		conn.Close()
+		<-served // the server is done with cfg before useTestConfig restores it
+	}()
+	replies := bufio.NewReader(conn)
+	ask := func(command string) string {
+		conn.Write([]byte(command + "\n"))
+		reply, err := replies.ReadString('\n')
+		if err != nil {
+			t.Fatalf("%s: %v", command, err)
+		}
+		return reply
+	}
+
+	_, port, _ := net.SplitHostPort(conn.LocalAddr().String())
+	if got := ask("/echo ip"); got != "127.0.0.1\n" {
+		t.Errorf("/echo ip = %q, want %q", got, "127.0.0.1\n")
+	}
+	if got := ask("/echo port"); got != port+"\n" {
+		t.Errorf("/echo port = %q, want %q", got, port+"\n")
+	}
+	if got, want := ask("/echo addr"), conn.LocalAddr().String()+"\n"; got != want {
+		t.Errorf("/echo addr = %q, want %q", got, want)
+	}
+}
+
+func TestEchoRepeatChar(t *testing.T) {
+	useTestConfig(t)
+	tests := []struct {
+		char     string
+		n        int
+		accepted bool
+	}{
+		{"A", 10, true},
+		{"A", maxMessageSize - 1, true}, // reply plus newline is exactly maxMessageSize
+		{"A", maxMessageSize, false},
+		{"é", (maxMessageSize - 1) / 2, true}, // 2 bytes each
+		{"é", (maxMessageSize-1)/2 + 1, false},
+		{"世", (maxMessageSize - 1) / 3, true}, // 3 bytes each
+		{"世", (maxMessageSize-1)/3 + 1, false},
+		{"🎉", (maxMessageSize - 1) / 4, true}, // 4 bytes each
+		{"🎉", (maxMessageSize-1)/4 + 1, false},
+	}
+	for _, tt := range tests {
+		command := fmt.Sprintf("/echo repeat-char %s %d", tt.char, tt.n)
+		reply := runCommand(t, command)
+		if !tt.accepted {
+			if !strings.HasPrefix(reply, "n cannot be more than") {
+				t.Errorf("%q replied %q, want a refusal", command, reply)
+			}
+			continue
+		}
+		if want := strings.Repeat(tt.char, tt.n) + "\n"; reply != want {
+			t.Errorf("%q replied %d bytes, want %d", command, len(reply), len(want))
+		}
+		if len(reply) > maxMessageSize {
+			t.Errorf("%q replied %d bytes, more than maxMessageSize", command, len(reply))
+		}
+	}
+
+	for _, bad := range []string{"/echo repeat-char AB 3", "/echo repeat-char A 0", "/echo repeat-char A x", "/echo repeat-char"} {
+		if reply := runCommand(t, bad); !strings.HasPrefix(reply, "Usage:") {
+			t.Errorf("%q replied %q, want usage", bad, reply)
+		}
+	}
+}
+
+func TestEchoContains(t *testing.T) {
+	useTestConfig(t)
+	tests := []struct{ in, want string }{
+		{"/echo contains hello world -- lo w", "true"},
+		{"/echo contains hello world -- xyz", "false"},
+		{"/echo contains Hello -- hell", "false"},
+		{"/echo contains -i Hello -- hELL", "true"},
+		{"/echo contains -i -- x -- -- x", "true"}, // -i, then "-- x" contains "-- x"
+		{"/echo contains a -- b -- c", "false"},    // needle is "b -- c", so the haystack can never hold " -- "
+		{"/echo contains a b -- c -- b", "false"},
+		{"/echo contains a--b -- a--b", "true"}, // only " -- " with spaces splits
+		{"/echo hasprefix foobar -- foo", "true"},
+		{"/echo hasprefix foobar -- bar", "false"},
+		{"/echo hassuffix foobar -- bar", "true"},
+		{"/echo hassuffix foobar -- Bar", "false"},
+		{"/echo hasprefix -i Foo -- f", "false"}, // -i belongs to contains only
+		{"/echo contains foobar", "Usage: /echo contains <s> -- <arg>"},
+		{"/echo hassuffix foobar --", "Usage: /echo hassuffix <s> -- <arg>"},
+	}
+	for _, tt := range tests {
+		if got := runCommand(t, tt.in); got != tt.want+"\n" {
+			t.Errorf("%q replied %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestArgDelimiterUsesFirstOccurrence(t *testing.T) {
+	s, needle, found := strings.Cut("hay stack -- nee -- dle", argDelimiter)
+	if !found || s != "hay stack" || needle != "nee -- dle" {
+		t.Errorf("Cut on argDelimiter = %q, %q, %v", s, needle, found)
+	}
+}
+
+func TestEchoUpperLowerKeepFormat(t *testing.T) {
+	useTestConfig(t)
+	conn := &httpConn{remote: "192.0.2.1:4321"}
+	session := newClientSession(conn)
+	session.format = "rot13"
+
+	for _, message := range []string{"/echo upper Hello Wörld", "/echo lower Hello Wörld", "abc"} {
+		if err := handleClientMessage(session, message); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := "HELLO WÖRLD\nhello wörld\nnop\n"; conn.String() != want {
+		t.Errorf("replies = %q, want %q", conn.String(), want)
+	}
+	if session.format != "rot13" {
+		t.Errorf("session.format = %q after /echo upper and lower, want rot13", session.format)
+	}
+}
+
+func TestTimezonesSearchPages(t *testing.T) {
+	useTestConfig(t)
+	if len(listTimezones()) == 0 {
+		t.Skip("no timezone database")
+	}
+	for _, command := range []string{"/timezones search", "/timezones search   ", "/timezones search a 0", "/timezones search a x"} {
+		if got := runCommand(t, command); !strings.HasPrefix(got, timezonesUsage) {
+			t.Errorf("%q replied %q, want usage", command, got)
+		}
+	}
+	if got, want := runCommand(t, "/timezones search europe/lond"), "Europe/London\nPage 1 of 1.\n"; got != want {
+		t.Errorf("search europe/lond replied %q, want %q", got, want)
+	}
+
+	first := runCommand(t, "/timezones search A")
+	if n := strings.Count(first, "\n"); n != timezonesPerPage+1 {
+		t.Errorf("search A page 1 has %d lines, want %d zones and the page line", n, timezonesPerPage)
+	}
+	if len(first) > maxMessageSize {
+		t.Errorf("search A page 1 is %d bytes, more than maxMessageSize", len(first))
+	}
+	if second := runCommand(t, "/timezones search A 2"); second == first || !strings.Contains(second, "Page 2 of ") {
+		t.Errorf("search A 2 replied %q", second)
+	}
+}
+
+func TestGetVar(t *testing.T) {
+	useTestConfig(t)
+	conn := &httpConn{remote: "192.0.2.1:4321"}
+	session := newClientSession(conn)
+	for _, message := range []string{"/setvar name world", "/getvar name", "/getvar other", "/getvar"} {
+		if err := handleClientMessage(session, message); err != nil {
+			t.Fatalf("%q: %v", message, err)
+		}
+	}
+	want := "name set.\nworld\nVariable other not set.\nUsage: /getvar <name>\n"
+	if got := conn.String(); got != want {
+		t.Errorf("replies = %q, want %q", got, want)
+	}
+}
+
+func TestEchoTemplateSeq(t *testing.T) {
+	useTestConfig(t)
+	conn := &httpConn{remote: "192.0.2.1:4321"}
+	session := newClientSession(conn)
+	for _, message := range []string{"hi", "/echo template {{.Seq}}/{{.MsgCount}}", "hi", "/echo template {{.Seq}}/{{.MsgCount}}"} {
+		session.msgCount.Add(1) // as handleConnection counts every message
+		if err := handleClientMessage(session, message); err != nil {
+			t.Fatalf("%q: %v", message, err)
+		}
+	}
+	if got, want := conn.String(), "hi\n1/2\nhi\n2/4\n"; got != want {
+		t.Errorf("replies = %q, want %q", got, want)
+	}
+}
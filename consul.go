@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var consulClient = &http.Client{Timeout: 5 * time.Second}
+
+type consulCheck struct {
+	TCP      string `json:"TCP"`
+	Interval string `json:"Interval"`
+	Timeout  string `json:"Timeout"`
+}
+
+type consulService struct { // body of PUT /v1/agent/service/register
+	ID    string      `json:"ID"`
+	Name  string      `json:"Name"`
+	Port  int         `json:"Port"`
+	Check consulCheck `json:"Check"`
+}
+
+func consulURL(addr, path string) string { // accepts host:port as well as a full URL
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	return strings.TrimRight(addr, "/") + path
+}
+
+func registerConsul(consulAddr, name string, echoAddr net.Addr) (string, error) { // returns the service ID to deregister with
+	tcpAddr, ok := echoAddr.(*net.TCPAddr)
+	if !ok {
+		return "", fmt.Errorf("unexpected listener address %s", echoAddr)
+	}
+	checkHost := "127.0.0.1" // the agent runs alongside us, so a wildcard listener is reachable on loopback
+	if !tcpAddr.IP.IsUnspecified() {
+		checkHost = tcpAddr.IP.String()
+	}
+
+	service := consulService{
+		ID:   fmt.Sprintf("%s-%s-%d", name, serverHostname, tcpAddr.Port),
+		Name: name,
+		Port: tcpAddr.Port,
+		Check: consulCheck{
+			TCP:      net.JoinHostPort(checkHost, strconv.Itoa(tcpAddr.Port)),
+			Interval: "10s",
+			Timeout:  "2s",
+		},
+	}
+	body, err := json.Marshal(service)
+	if err != nil {
+		return "", err
+	}
+
+	if err := consulPut(consulURL(consulAddr, "/v1/agent/service/register"), body); err != nil {
+		return "", err
+	}
+	return service.ID, nil
+}
+
+func deregisterConsul(consulAddr, id string) error {
+	return consulPut(consulURL(consulAddr, "/v1/agent/service/deregister/"+id), nil)
+}
+
+func consulPut(url string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := consulClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("consul returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	diagnosticsPing = "diagnostics-ping"
+	maxClockSkew    = 5 * time.Second // difference from the client's clock that counts as a failure
+)
+
+func handleDiagnostics(session *clientSession, args string) error { // self-tests, optionally given the client's time as RFC3339
+	var lines, failed []string
+	report := func(name, status, detail string) {
+		lines = append(lines, strings.TrimRight(fmt.Sprintf("%-12s %-6s %s", name+":", status, detail), " "))
+	}
+	check := func(name string, ok bool, detail string) {
+		status := "ok"
+		if !ok {
+			status = "FAILED"
+			failed = append(failed, name)
+		}
+		report(name, status, detail)
+	}
+
+	report("Hostname", "-", serverHostname)
+
+	// Run a message through the normal echo path on a throwaway session
+	loopback := &httpConn{remote: "diagnostics"}
+	probe := newClientSession(loopback)
+	defer probe.Close()
+	err := handleClientMessage(probe, diagnosticsPing)
+	check("Loopback", err == nil && strings.Contains(loopback.String(), diagnosticsPing),
+		fmt.Sprintf("echoed %q", strings.TrimSuffix(loopback.String(), "\n")))
+
+	now := time.Now()
+	if args == "" {
+		report("Clock", "-", fmt.Sprintf("server time %s; send /diagnostics <RFC3339 time> to compare clocks", now.Format(time.RFC3339)))
+	} else if clientTime, err := time.Parse(time.RFC3339Nano, args); err != nil {
+		check("Clock", false, fmt.Sprintf("cannot parse %q as an RFC3339 time", args))
+	} else {
+		skew := now.Sub(clientTime)
+		check("Clock", skew.Abs() <= maxClockSkew, fmt.Sprintf("server is %s ahead of the client", skew.Round(time.Millisecond)))
+	}
+
+	if tlsConn, ok := session.conn.(*tls.Conn); ok {
+		state := tlsConn.ConnectionState()
+		report("TLS", "on", tls.VersionName(state.Version)+", "+tls.CipherSuiteName(state.CipherSuite))
+	} else {
+		report("TLS", "off", "")
+	}
+
+	if len(session.hmacKey) > 0 {
+		report("HMAC", "on", "echoes carry an HMAC-SHA256 tag")
+	} else {
+		report("HMAC", "off", "")
+	}
+
+	active := stats.activeConnections.Load() // includes this session
+	check("Workers", active < int64(cfg.maxWorkers), fmt.Sprintf("%d of %d slots in use", active, cfg.maxWorkers))
+
+	if len(failed) == 0 {
+		lines = append(lines, "Diagnostics: all checks passed.")
+	} else {
+		lines = append(lines, "Diagnostics: failed checks: "+strings.Join(failed, ", ")+".")
+	}
+	return writeLine(session, strings.Join(lines, "\n"))
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+var draining atomic.Bool // set once a drain signal has closed the listener
+
+const drainLogInterval = 10 * time.Second
+
+func logDraining(finished <-chan struct{}) { // reports the sessions left until they have all ended; not under -quiet
+	ticker := time.NewTicker(drainLogInterval)
+	defer ticker.Stop()
+
+	for {
+		fmt.Printf("[%s] Draining: no longer accepting new connections. %d sessions active.\n",
+			logTimestamp(), stats.activeConnections.Load())
+		select {
+		case <-finished:
+			return
+		case <-ticker.C:
+		}
+	}
+}
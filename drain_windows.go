@@ -0,0 +1,7 @@
+//go:build windows
+
+package main
+
+import "os"
+
+var drainSignals []os.Signal // Windows has no SIGUSR1, so draining isn't available
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+func handleEchoCSV(session *clientSession, line string) error {
+	if line == "" {
+		return writeLine(session, "Usage: /echo csv <csv line>")
+	}
+	fields, err := csv.NewReader(strings.NewReader(line)).Read()
+	if err != nil {
+		return writeLine(session, fmt.Sprintf("CSV parse error: %v.", err))
+	}
+	out := canonicalCSV(fields)
+	if len(out)+1 > maxMessageSize { // quoting can push a line that fit over the limit
+		return writeLine(session, fmt.Sprintf("Message cannot be more than %d bytes.", maxMessageSize))
+	}
+	return writeLine(session, out)
+}
+
+func canonicalCSV(fields []string) string { // every field quoted, which encoding/csv's Writer only does when it has to
+	quoted := make([]string, len(fields))
+	for i, f := range fields {
+		quoted[i] = `"` + strings.ReplaceAll(f, `"`, `""`) + `"`
+	}
+	return strings.Join(quoted, ",")
+}
+
+// handleEchoJSONFormat reformats a JSON document. json.Indent and json.Compact
+// work on the text itself, so key order and number precision survive, which
+// a round trip through interface{} would lose.
+func handleEchoJSONFormat(session *clientSession, mode, doc string) error {
+	if doc == "" {
+		return writeLine(session, fmt.Sprintf("Usage: /echo json %s <json>", mode))
+	}
+	var out bytes.Buffer
+	var err error
+	if mode == "pretty" {
+		err = json.Indent(&out, []byte(doc), "", "  ")
+	} else {
+		err = json.Compact(&out, []byte(doc))
+	}
+	if err != nil {
+		return writeLine(session, fmt.Sprintf("JSON parse error: %v.", err))
+	}
+	if out.Len()+1 > maxMessageSize {
+		return writeLine(session, fmt.Sprintf("Output too large to echo, the limit is %d bytes.", maxMessageSize))
+	}
+	return writeLine(session, out.String())
+}
+
+const urlUsage = "Usage: /echo url encode [query|path] <string> | /echo url decode <encoded>"
+
+func handleEchoURL(session *clientSession, args string) error {
+	op, s := splitArg(args)
+	var out string
+	switch op {
+	case "encode":
+		escape := url.QueryEscape // spaces become +
+		if style, rest := splitArg(s); style == "query" || style == "path" {
+			if style == "path" {
+				escape = url.PathEscape // spaces become %20
+			}
+			s = rest
+		}
+		if s == "" {
+			return writeLine(session, urlUsage)
+		}
+		out = escape(s)
+	case "decode":
+		if s == "" {
+			return writeLine(session, urlUsage)
+		}
+		var err error
+		if out, err = url.QueryUnescape(s); err != nil {
+			return writeLine(session, fmt.Sprintf("Cannot decode: %v.", err))
+		}
+	default:
+		return writeLine(session, urlUsage)
+	}
+	if len(out)+1 > maxMessageSize { // escaping can triple the length
+		return writeLine(session, fmt.Sprintf("Output too large to echo, the limit is %d bytes.", maxMessageSize))
+	}
+	return writeLine(session, out)
+}
+
+const xmlUsage = "Usage: /echo xml validate <xml> | /echo xml escape <text>"
+
+func handleEchoXML(session *clientSession, args string) error {
+	op, s := splitArg(args)
+	if s == "" {
+		return writeLine(session, xmlUsage)
+	}
+	switch op {
+	case "validate":
+		if err := checkXML(s); err != nil {
+			return writeLine(session, fmt.Sprintf("XML error: %v.", err))
+		}
+		return writeLine(session, "Valid XML.")
+	case "escape":
+		var out strings.Builder
+		xml.EscapeText(&out, []byte(s)) // writing to a strings.Builder can't fail
+		if out.Len()+1 > maxMessageSize {
+			return writeLine(session, fmt.Sprintf("Output too large to echo, the limit is %d bytes.", maxMessageSize))
+		}
+		return writeLine(session, out.String())
+	default:
+		return writeLine(session, xmlUsage)
+	}
+}
+
+// checkXML reports whether doc is well-formed: it must parse, and hold exactly
+// one root element with nothing but whitespace, comments and processing
+// instructions around it. The decoder alone is happy with several roots, or none.
+func checkXML(doc string) error {
+	d := xml.NewDecoder(strings.NewReader(doc)) // strict by default, so unknown entities fail
+	depth, roots := 0, 0
+	for {
+		offset := d.InputOffset()
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var syntax *xml.SyntaxError
+		if errors.As(err, &syntax) {
+			return fmt.Errorf("offset %d: %s", d.InputOffset(), syntax.Msg)
+		}
+		if err != nil {
+			return fmt.Errorf("offset %d: %v", d.InputOffset(), err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				if roots++; roots > 1 {
+					return fmt.Errorf("offset %d: more than one root element", offset)
+				}
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			if depth == 0 && len(bytes.TrimSpace(t)) > 0 {
+				return fmt.Errorf("offset %d: text outside the root element", offset)
+			}
+		}
+	}
+	if roots == 0 {
+		return errors.New("offset 0: no root element")
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEchoCSV(t *testing.T) {
+	useTestConfig(t)
+	tests := []struct {
+		name, in, want string
+	}{
+		{"unquoted", `a,b,c`, `"a","b","c"`},
+		{"single field", `solo`, `"solo"`},
+		{"comma in field", `"x, y",z`, `"x, y","z"`},
+		{"quotes in field", `"say ""hi""",q`, `"say ""hi""","q"`},
+		{"empty fields", `a,,b,`, `"a","","b",""`},
+		{"leading empty field", `,a`, `"","a"`},
+		{"already canonical", `"a","b"`, `"a","b"`},
+		{"spaces kept", `a , b`, `"a "," b"`},
+		{"unicode", `é,世界`, `"é","世界"`},
+		{"bare quote", `a"b,c`, `CSV parse error: parse error on line 1, column 2: bare " in non-quoted-field.`},
+		{"unterminated quote", `"open`, `CSV parse error: parse error on line 1, column 6: extraneous or missing " in quoted-field.`},
+		{"no input", ``, `Usage: /echo csv <csv line>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command := strings.TrimSpace("/echo csv " + tt.in)
+			if got := runCommand(t, command); got != tt.want+"\n" {
+				t.Errorf("%q replied %q, want %q", command, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalCSVTooLong(t *testing.T) {
+	useTestConfig(t)
+	fields := strings.TrimSuffix(strings.Repeat("x,", 400), ",") // 799 bytes in, 1599 once quoted
+	if got := runCommand(t, "/echo csv "+fields); !strings.HasPrefix(got, "Message cannot be more than") {
+		t.Errorf("oversized CSV replied %d bytes: %.40q", len(got), got)
+	}
+}
+
+func TestCheckXML(t *testing.T) {
+	tests := []struct {
+		name, doc string
+		wantErr   string // empty for a well-formed document
+	}{
+		{"simple element", `<a/>`, ""},
+		{"nested", `<a><b>text</b><c x="1"/></a>`, ""},
+		{"declaration", `<?xml version="1.0" encoding="UTF-8"?><a>x</a>`, ""},
+		{"namespaces", `<r xmlns="urn:a" xmlns:p="urn:b"><p:x p:attr="v"/></r>`, ""},
+		{"comment and whitespace around root", " <!-- hi --> <a/> \n", ""},
+		{"predefined and numeric entities", `<a>&amp;&lt;&gt;&quot;&apos;&#65;&#x42;</a>`, ""},
+		{"cdata", `<a><![CDATA[<not a tag>]]></a>`, ""},
+		{"unclosed tag", `<a><b></b>`, "unexpected EOF"},
+		{"mismatched tags", `<a><b></a></b>`, "element <b> closed by </a>"},
+		{"invalid entity", `<a>&nbsp;</a>`, "invalid character entity &nbsp;"},
+		{"bare ampersand", `<a>fish & chips</a>`, "invalid character entity"},
+		{"multiple roots", `<a/><b/>`, "offset 4: more than one root element"},
+		{"no root", `<!-- only a comment -->`, "offset 0: no root element"},
+		{"empty", ``, "offset 0: no root element"},
+		{"text outside root", `<a/>trailing`, "offset 4: text outside the root element"},
+		{"unquoted attribute", `<a b=1/>`, "unquoted or missing attribute value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkXML(tt.doc)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("checkXML(%q) = %v, want nil", tt.doc, err)
+			case tt.wantErr != "" && err == nil:
+				t.Errorf("checkXML(%q) = nil, want an error containing %q", tt.doc, tt.wantErr)
+			case tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr):
+				t.Errorf("checkXML(%q) = %v, want an error containing %q", tt.doc, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEchoXML(t *testing.T) {
+	useTestConfig(t)
+	tests := []struct {
+		command, want string
+	}{
+		{`/echo xml validate <a><b/></a>`, "Valid XML."},
+		{`/echo xml validate <a><b></a>`, "XML error: offset 10: element <b> closed by </a>."},
+		{`/echo xml escape <a href="x">Tom & Jerry</a>`, "&lt;a href=&#34;x&#34;&gt;Tom &amp; Jerry&lt;/a&gt;"},
+		{`/echo xml escape it's`, "it&#39;s"},
+		{`/echo xml escape plain text`, "plain text"},
+		{`/echo xml escape tab` + "\t" + `here`, "tab&#x9;here"},
+		{`/echo xml validate`, xmlUsage},
+		{`/echo xml frobnicate <a/>`, xmlUsage},
+	}
+	for _, tt := range tests {
+		if got := runCommand(t, tt.command); got != tt.want+"\n" {
+			t.Errorf("%q replied %q, want %q", tt.command, got, tt.want)
+		}
+	}
+
+	long := "/echo xml escape " + strings.Repeat("&", maxMessageSize/4) // each & becomes &amp;, five times as long
+	if got := runCommand(t, long); !strings.HasPrefix(got, "Output too large to echo") {
+		t.Errorf("oversized escape replied %d bytes: %.40q", len(got), got)
+	}
+}
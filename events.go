@@ -0,0 +1,57 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+type Event struct { // one server event, as returned by GET /api/events
+	Time   time.Time `json:"timestamp"`
+	Type   string    `json:"type"`
+	Detail string    `json:"detail"`
+}
+
+type EventRing struct { // fixed-size buffer of the most recent events
+	mu       sync.Mutex
+	events   []Event
+	head     int // index of the oldest event
+	size     int
+	capacity int
+}
+
+var events *EventRing
+
+func newEventRing(capacity int) *EventRing {
+	return &EventRing{events: make([]Event, capacity), capacity: capacity}
+}
+
+func (r *EventRing) Push(eventType, detail string) { // adds an event, dropping the oldest when full
+	if r == nil || r.capacity == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e := Event{Time: time.Now(), Type: eventType, Detail: detail}
+	if r.size < r.capacity {
+		r.events[(r.head+r.size)%r.capacity] = e
+		r.size++
+		return
+	}
+	r.events[r.head] = e
+	r.head = (r.head + 1) % r.capacity
+}
+
+func (r *EventRing) Since(t time.Time) []Event { // events after t, oldest first
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make([]Event, 0, r.size)
+	for i := 0; i < r.size; i++ {
+		e := r.events[(r.head+i)%r.capacity]
+		if e.Time.After(t) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+func serveFIFO(in, out *os.File) { // echoes each read from the input FIFO to the output FIFO, one at a time
+	defer in.Close()
+	defer out.Close()
+
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, err := in.Read(buf)
+		if err != nil {
+			fmt.Printf("[%s] FIFO %s: read failed: %v\n", logTimestamp(), in.Name(), err)
+			return
+		}
+
+		trimmed := strings.TrimSpace(string(buf[:n]))
+		if trimmed == "" {
+			continue
+		}
+
+		if _, err := out.Write([]byte(signMessage(cfg.hmacKey, trimmed) + "\n")); err != nil {
+			fmt.Printf("[%s] FIFO %s: write failed: %v\n", logTimestamp(), out.Name(), err)
+			return
+		}
+	}
+}
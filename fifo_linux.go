@@ -0,0 +1,27 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+func openFIFO(path string) (*os.File, error) { // creates the FIFO if needed and opens it without blocking for a peer
+	err := syscall.Mkfifo(path, 0644)
+	if err != nil && !errors.Is(err, os.ErrExist) {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode()&os.ModeNamedPipe == 0 {
+		return nil, fmt.Errorf("%s exists and is not a named pipe", path)
+	}
+
+	return os.OpenFile(path, os.O_RDWR, 0) // O_RDWR so open doesn't wait for the other end
+}
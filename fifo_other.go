@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+func openFIFO(path string) (*os.File, error) {
+	return nil, errors.New("-fifo is only supported on Linux")
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+var geoDB *maxminddb.Reader // opened from -geoip-db, nil when unset
+
+const geoLookupTimeout = 100 * time.Millisecond
+
+type geoRecord struct { // the fields we use from a GeoLite2 City or Country database
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+func geoAnnotation(addr net.Addr) string { // ", country=US, city=Seattle" for logConnection, or "" if unknown
+	if geoDB == nil {
+		return ""
+	}
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return ""
+	}
+
+	type result struct {
+		record geoRecord
+		err    error
+	}
+	done := make(chan result, 1) // buffered, so a slow lookup can finish after we've given up on it
+	go func() {
+		var r result
+		r.err = geoDB.Lookup(tcpAddr.IP, &r.record)
+		done <- r
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			logDebug("GeoIP lookup for %s failed: %v", tcpAddr.IP, r.err)
+			return ""
+		}
+		if r.record.Country.ISOCode == "" {
+			logDebug("GeoIP has no record for %s", tcpAddr.IP)
+			return ""
+		}
+		annotation := ", country=" + r.record.Country.ISOCode
+		if city := r.record.City.Names["en"]; city != "" {
+			annotation += ", city=" + city
+		}
+		return annotation
+	case <-time.After(geoLookupTimeout):
+		logDebug("GeoIP lookup for %s timed out after %s", tcpAddr.IP, geoLookupTimeout)
+		return ""
+	}
+}
+
+func openGeoDB(path string) (*maxminddb.Reader, error) {
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("GeoIP database %s: %v", path, err)
+	}
+	return db, nil
+}
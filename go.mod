@@ -1,3 +1,8 @@
 module github.com/spector-asael/echo-server
 
 go 1.23.5
+
+require (
+	github.com/oschwald/maxminddb-golang v1.13.1
+	golang.org/x/sys v0.30.0
+)
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+var shuttingDown atomic.Bool // set by the signal handler before the listener closes
+
+func serveHealth(addr string, workerPool chan struct{}) { // liveness and readiness probes, runs until the process exits
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("GET /livez", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok") // answering at all means the process is alive
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case shuttingDown.Load():
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		case len(workerPool) >= cap(workerPool):
+			http.Error(w, "worker pool full", http.StatusServiceUnavailable)
+		default:
+			fmt.Fprintln(w, "ok")
+		}
+	})
+
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	if err := server.ListenAndServe(); err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] Health server on %s stopped: %v\n", logTimestamp(), addr, err)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type httpEchoRequest struct {
+	Message string `json:"message"`
+}
+
+type httpEchoResponse struct {
+	Echo      string `json:"echo"`
+	Timestamp string `json:"timestamp"`
+}
+
+func serveHTTP(addr string) { // runs the HTTP API until the process exits
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/echo", handleHTTPEcho)
+	mux.HandleFunc("GET /api/events", handleHTTPEvents)
+	mux.HandleFunc("GET /api/download-log", handleHTTPDownloadLog)
+
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	if err := server.ListenAndServe(); err != nil {
+		fmt.Fprintf(os.Stderr, "[%s] HTTP server on %s stopped: %v\n", logTimestamp(), addr, err)
+	}
+}
+
+func handleHTTPEcho(w http.ResponseWriter, r *http.Request) { // runs one message through handleClientMessage
+	var req httpEchoRequest
+	body := http.MaxBytesReader(w, r.Body, 4*int64(maxMessageSize)) // room for JSON escaping
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		http.Error(w, "Body must be JSON like {\"message\":\"...\"}.", http.StatusBadRequest)
+		return
+	}
+
+	message := strings.TrimSpace(req.Message)
+	if message == "" {
+		http.Error(w, "Message must not be empty.", http.StatusBadRequest)
+		return
+	}
+	if len(message)+1 > maxMessageSize {
+		http.Error(w, fmt.Sprintf("Message cannot be more than %d bytes.", maxMessageSize-1), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	conn := &httpConn{remote: r.RemoteAddr}
+	session := newClientSession(conn)
+	defer session.Close()
+	session.bytesIn.Add(int64(len(message)))
+	session.msgCount.Add(1)
+	stats.recordMessage(len(message))
+
+	var err error
+	if cfg.noCommands { // same as handleEcho: no hello, bye or /commands, just the message back
+		err = writeLine(session, message)
+	} else {
+		err = handleClientMessage(session, message)
+	}
+	session.Close()
+	var disconnect *DisconnectError
+	if err != nil && !errors.As(err, &disconnect) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(httpEchoResponse{
+		Echo:      strings.TrimSuffix(conn.String(), "\n"),
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
+func handleHTTPEvents(w http.ResponseWriter, r *http.Request) { // admin only: recent events, optionally ?since=<RFC3339>
+	if !httpAdmin(r) {
+		http.Error(w, "Admin password required: Authorization: Bearer <password>.", http.StatusUnauthorized)
+		return
+	}
+
+	var since time.Time
+	if s := r.URL.Query().Get("since"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			http.Error(w, "since must be an RFC3339 time.", http.StatusBadRequest)
+			return
+		}
+		since = t
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events.Since(since))
+}
+
+func handleHTTPDownloadLog(w http.ResponseWriter, r *http.Request) { // admin only: streams ?file=client_<addr>.log[.gz]
+	if !httpAdmin(r) {
+		http.Error(w, "Admin password required: Authorization: Bearer <password>.", http.StatusUnauthorized)
+		return
+	}
+
+	name := r.URL.Query().Get("file")
+	if name != filepath.Base(name) || !strings.HasPrefix(name, "client_") {
+		http.Error(w, "file must be a client log name, e.g. client_127.0.0.1_50000.log.gz.", http.StatusBadRequest)
+		return
+	}
+
+	file, err := os.Open(filepath.Join(cfg.logDir, name))
+	if err != nil {
+		http.Error(w, "No such log file.", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	if strings.HasSuffix(name, ".gz") {
+		w.Header().Set("Content-Type", "application/gzip")
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	io.Copy(w, file)
+}
+
+func httpAdmin(r *http.Request) bool { // checks the request carries the -admin-password as a bearer token
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && cfg.adminPassword != "" &&
+		subtle.ConstantTimeCompare([]byte(token), []byte(cfg.adminPassword)) == 1
+}
+
+type httpConn struct { // net.Conn that collects a command's output for an HTTP response
+	mu     sync.Mutex
+	out    bytes.Buffer
+	remote string
+}
+
+func (c *httpConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.out.Write(p)
+}
+
+func (c *httpConn) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.out.String()
+}
+
+func (c *httpConn) Read(p []byte) (int, error)         { return 0, errors.New("httpConn is write-only") }
+func (c *httpConn) Close() error                       { return nil }
+func (c *httpConn) LocalAddr() net.Addr                { return httpAddr("local") }
+func (c *httpConn) RemoteAddr() net.Addr               { return httpAddr(c.remote) }
+func (c *httpConn) SetDeadline(t time.Time) error      { return nil }
+func (c *httpConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *httpConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type httpAddr string
+
+func (a httpAddr) Network() string { return "http" }
+func (a httpAddr) String() string  { return string(a) }
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func postEcho(t *testing.T, message string) string { // the "echo" field of POST /api/echo
+	t.Helper()
+	body, _ := json.Marshal(httpEchoRequest{Message: message})
+	rec := httptest.NewRecorder()
+	handleHTTPEcho(rec, httptest.NewRequest(http.MethodPost, "/api/echo", strings.NewReader(string(body))))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST %q: status %d: %s", message, rec.Code, rec.Body)
+	}
+	var resp httpEchoResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Echo
+}
+
+func TestHTTPEchoNoCommands(t *testing.T) {
+	useTestConfig(t)
+	cfg.noCommands = true
+
+	for _, message := range []string{"hello", "bye", "/echo type 12", "/help"} {
+		if got := postEcho(t, message); got != message {
+			t.Errorf("with -no-commands, POST %q echoed %q", message, got)
+		}
+	}
+}
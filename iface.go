@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"net"
+)
+
+func resolveInterfaceIP(name string, ipv6 bool) (net.IP, *net.Interface, error) { // first usable address on the named interface
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("interface %s: %v", name, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, nil, fmt.Errorf("interface %s: %v", name, err)
+	}
+
+	var loopback net.IP // only used if the interface has nothing else, e.g. lo
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || (ipNet.IP.To4() == nil) != ipv6 || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if !ipNet.IP.IsLoopback() {
+			return ipNet.IP, iface, nil
+		}
+		if loopback == nil {
+			loopback = ipNet.IP
+		}
+	}
+	if loopback != nil {
+		return loopback, iface, nil
+	}
+
+	family := "IPv4"
+	if ipv6 {
+		family = "IPv6"
+	}
+	return nil, nil, fmt.Errorf("interface %s has no %s address", name, family)
+}
@@ -0,0 +1,55 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const rtmgrpLink = 0x1 // netlink multicast group for link events, not defined in syscall
+
+func watchInterface(iface *net.Interface) { // logs a warning when the kernel reports the interface down
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW, syscall.NETLINK_ROUTE)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: cannot watch interface %s: %v\n", iface.Name, err)
+		return
+	}
+	defer syscall.Close(fd)
+
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: rtmgrpLink}); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: cannot watch interface %s: %v\n", iface.Name, err)
+		return
+	}
+
+	buf := make([]byte, os.Getpagesize())
+	for {
+		n, err := syscall.Read(fd, buf)
+		if err != nil {
+			return
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			continue
+		}
+
+		for _, msg := range msgs {
+			if msg.Header.Type != syscall.RTM_NEWLINK && msg.Header.Type != syscall.RTM_DELLINK {
+				continue
+			}
+			if len(msg.Data) < syscall.SizeofIfInfomsg {
+				continue
+			}
+			info := (*syscall.IfInfomsg)(unsafe.Pointer(&msg.Data[0]))
+			if int(info.Index) != iface.Index {
+				continue
+			}
+			if msg.Header.Type == syscall.RTM_DELLINK || info.Flags&syscall.IFF_UP == 0 {
+				fmt.Fprintf(os.Stderr, "[%s] Warning: interface %s is down\n", logTimestamp(), iface.Name)
+			}
+		}
+	}
+}
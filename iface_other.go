@@ -0,0 +1,7 @@
+//go:build !linux
+
+package main
+
+import "net"
+
+func watchInterface(iface *net.Interface) {} // interface events are only watched on Linux
@@ -1,20 +1,32 @@
 package main
 
 import (
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"net"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
 func worker(conn net.Conn, wg *sync.WaitGroup, workerPool chan struct{}) {
 
+	start := time.Now()
+	stats.connectionOpened()
 	defer func() {
+		stats.connectionClosed(time.Since(start))
 		<-workerPool // Release slot
 		wg.Done()
 	}()
@@ -27,33 +39,63 @@ func handleConnection(conn net.Conn) { // Function to handle connections
 	defer logDisconnection(conn) // Log clients that disconnect
 	defer conn.Close()
 
+	var handshakeErr error
+	if tlsConn, ok := conn.(*tls.Conn); ok { // finish the handshake first so logConnection can show the SNI name
+		tlsConn.SetDeadline(time.Now().Add(idleTimeout))
+		handshakeErr = tlsConn.Handshake()
+		tlsConn.SetDeadline(time.Time{})
+	}
+
 	logConnection(conn) // Log clients that connect
+	if handshakeErr != nil {
+		logError(conn, handshakeErr)
+		return
+	}
 
-	err := handleEcho(conn)
+	handle := handleEcho
+	if cfg.rfc862 {
+		handle = handleRFC862
+	}
+	err := handle(conn)
 	if err != nil {
 		logError(conn, err) // Echo server logic
 	}
 }
+
+const maxMessageSize int = 1024
+const maxBannerSize = 512
+const idleTimeout = 30 * time.Second
+
 func handleEcho(conn net.Conn) error {
-	const maxMessageSize int = 1024
 	buf := make([]byte, maxMessageSize)
 
-	logger, err := newClientLogger(conn) // Create a clientLogger object that logs messages into a file
+	logger, err := newClientLogger(conn, cfg.logDir, cfg.compressLogs, cfg.logSync) // Create a clientLogger object that logs messages into a file
 	if err != nil {
 		return fmt.Errorf("failed to initialize logger: %v", err)
 	}
 	defer logger.Close()
 
+	session := newClientSession(conn)
+	session.logger = logger
+	defer session.Close()
+
+	if banner := currentBanner(); banner != "" {
+		if err := writeLine(session, banner); err != nil {
+			return err
+		}
+	}
+
 	for {
-		conn.SetReadDeadline(time.Now().Add(30 * time.Second)) // Time user out after 30 seconds
+		conn.SetReadDeadline(time.Now().Add(idleTimeout)) // Time user out after 30 seconds
 
 		n, err := conn.Read(buf)
 		if err != nil {
 			return err // Includes EOF
 		}
+		session.bytesIn.Add(int64(n))
 
 		if n == 1024 { // Reject input that is over 1024 bytes
-			conn.Write([]byte("Message cannot be more than 1024 bytes (1024 regular characters).\n"))
+			session.Write([]byte("Message cannot be more than 1024 bytes (1024 regular characters).\n"))
 			if flushErr := flushExtraInput(conn, buf, maxMessageSize); flushErr != nil {
 				// remove extra characters from the TCP Stream
 				return flushErr
@@ -66,81 +108,436 @@ func handleEcho(conn net.Conn) error {
 			continue // ignore empty input from user
 		}
 
-		if err := logger.Log(trimmed); err != nil { // log message into file
+		count := session.msgCount.Add(1)
+		stats.recordMessage(n)
+
+		if cfg.maxMessageCount > 0 && count > cfg.maxMessageCount {
+			session.Write([]byte("You have reached the maximum message count for this session. Disconnecting.\n"))
+			fmt.Printf("[%s] Client %s reached the message limit (%d bytes transferred)\n",
+				logTimestamp(), conn.RemoteAddr(), session.bytesIn.Load()+session.bytesOut.Load())
+			return errMessageLimit
+		}
+
+		if err := logger.Log(loggedForm(trimmed)); err != nil { // log message into file
 			return fmt.Errorf("failed to log message: %v", err)
 		}
 
-		if _, err := conn.Write([]byte(trimmed + "\n")); err != nil { // write message to user
+		if cfg.noCommands { // plain echo, not even hello and bye are special
+			if err := writeLine(session, trimmed); err != nil {
+				return err
+			}
+		} else if err := handleClientMessage(session, trimmed); err != nil { // echo message or run command
 			return err
 		}
+
+		if cfg.maxBytesPerSession > 0 {
+			transferred := session.bytesIn.Load() + session.bytesOut.Load()
+			if transferred > cfg.maxBytesPerSession {
+				session.Write([]byte("Session data limit reached. Disconnecting.\n"))
+				fmt.Printf("[%s] Client %s reached the session data limit (%d bytes transferred)\n",
+					logTimestamp(), conn.RemoteAddr(), transferred)
+				return errSessionDataLimit
+			}
+		}
+	}
+}
+
+func loggedForm(message string) string { // how a message appears in the client log
+	switch {
+	case strings.HasPrefix(message, "/auth "):
+		return "/auth ****" // never write the admin password to disk
+	case strings.HasPrefix(message, "/echo raw "):
+		return "[RAW] " + strings.TrimPrefix(message, "/echo raw ")
+	default:
+		return message
 	}
 }
 
+// Session limits end the connection on purpose and handleEcho logs them
+// itself, so they are DisconnectErrors rather than errors for logError.
+var (
+	errSessionDataLimit = &DisconnectError{Reason: "session data limit reached"}
+	errMessageLimit     = &DisconnectError{Reason: "session message limit reached"}
+)
+
 func logError(conn net.Conn, err error) { // logs keep track of errors
 
-	clientAddr := conn.RemoteAddr().String()
+	addr := conn.RemoteAddr().String()
 	logTime := func() string {
-		return time.Now().Format(time.RFC3339)
+		return logTimestamp()
 	}
 
-	addr := conn.RemoteAddr().String()
-	fmt.Println(err)
+	var disconnect *DisconnectError
+	if errors.As(err, &disconnect) {
+		return // the client left on purpose, logDisconnection covers it
+	}
+	if err != io.EOF {
+		stats.recordError()
+	}
 	if err == io.EOF {
-		fmt.Printf("[%s] Client %s closed the connection (EOF)\n", logTime(), addr)
+		fmt.Fprintf(os.Stderr, "[%s] Client %s closed the connection (EOF)\n", logTime(), addr)
+		events.Push("eof", addr)
 		// client closing connection error
 	} else {
 		netErr, ok := err.(net.Error)
 		if ok && netErr.Timeout() {
-			conn.Write([]byte("Connection timeout. Disconnecting...\n"))
-			fmt.Printf("[%s] Timeout: Client %s inactive for 30 seconds\n", logTime(), clientAddr)
+			timeout := idleTimeout
+			if cfg.rfc862 {
+				timeout = rfc862IdleTimeout // and no notice, the stream may only carry the client's own octets
+			} else {
+				conn.Write([]byte("Connection timeout. Disconnecting...\n"))
+			}
+			fmt.Fprintf(os.Stderr, "[%s] Timeout: Client %s inactive for %s\n", logTime(), addr, timeout)
+			events.Push("timeout", addr)
 			// timeout error
 			return
 		}
+		fmt.Fprintf(os.Stderr, "[%s] Error: Client %s: %v\n", logTime(), addr, err)
+		events.Push("error", fmt.Sprintf("%s: %v", addr, err))
 	}
 
 }
 func logConnection(conn net.Conn) {
-	address := conn.RemoteAddr().String()        // Grab address, convert to string
-	timestamp := time.Now().Format(time.RFC3339) // Grab current time
+	events.Push("connect", conn.RemoteAddr().String())
+	if cfg.quiet {
+		return
+	}
+	address := conn.RemoteAddr().String() // Grab address, convert to string
+	timestamp := logTimestamp()           // Grab current time
 
+	address += geoAnnotation(conn.RemoteAddr()) // waits at most geoLookupTimeout
+	if tlsConn, ok := conn.(*tls.Conn); ok && tlsConn.ConnectionState().ServerName != "" {
+		fmt.Printf("[%s] New Connection from %s (SNI %s)\n", timestamp, address, tlsConn.ConnectionState().ServerName)
+		return
+	}
 	fmt.Printf("[%s] New Connection from %s\n", timestamp, address)
 }
 
+func logDebug(format string, args ...any) { // only prints when -debug is set
+	if !cfg.debug {
+		return
+	}
+	timestamp := logTimestamp()
+	fmt.Printf("[%s] DEBUG: %s\n", timestamp, fmt.Sprintf(format, args...))
+}
+
 func logDisconnection(conn net.Conn) {
+	events.Push("disconnect", conn.RemoteAddr().String())
+	if cfg.quiet {
+		return
+	}
 	address := conn.RemoteAddr().String()
-	timestamp := time.Now().Format(time.RFC3339)
+	timestamp := logTimestamp()
 
 	fmt.Printf("[%s] Client %s has disconnected\n", timestamp, address)
 }
 
-func parseFlags() (string, int) {
+type config struct { // server settings collected from the command line
+	port       string
+	listenAddr string // port, or ip:port when -iface is set
+	randomPort bool   // -port 0, the OS assigns a free port
+	iface      string
+	ipv6       bool
+	maxWorkers int
+	hmacKey    []byte // when set, echoed messages carry an HMAC-SHA256 tag
+
+	adminPassword string // enables /auth and admin-only commands when set
+	auditLogPath  string // where admin actions are recorded when admin features are on
+	logDir        string // directory for client and audit logs
+	compressLogs  bool   // gzip client logs, written as .log.gz
+	logSync       bool   // fsync client logs after every line
+	echoPrefix    string // prepended to every echoed message
+	echoSuffix    string // appended to every echoed message, after any /format
+	debug         bool
+	quiet         bool // suppress the banner and connect/disconnect lines on stdout
+
+	statsInterval time.Duration // how often to log a stats summary, 0 to disable
+	fifoPath      string        // named pipe to read messages from; replies go to fifoPath + ".out"
+	pidFile       string
+
+	maxBytesPerSession   int64 // bytes in plus out before a client is disconnected, 0 for no limit
+	maxMessageCount      int64 // messages a client may send before being disconnected, 0 for no limit
+	dryRun               bool
+	flushTimeout         time.Duration // how long to discard the rest of an oversized message
+	httpAddr             string        // serve the HTTP API here when set
+	eventRingSize        int
+	healthAddr           string // serve /healthz, /livez and /readyz here when set
+	netns                string // path of a network namespace to listen in, Linux only
+	consulAddr           string // register with this Consul agent when set
+	serviceName          string
+	noCommands           bool                // echo every message as-is, without hello, bye or /commands
+	rfc862               bool                // raw byte echo as in RFC 862, see handleRFC862
+	bannerText           string              // sent to each client on connect when set
+	tlsConfig            *tls.Config         // serve TLS when -cert and -key are set
+	pprofAddr            string              // serve net/http/pprof here when set
+	normalize            bool                // collapse whitespace in echoes by default, see /normalize
+	logTimeFormat        string              // time layout for log lines, or "unix" or "unixmilli"
+	geoipDB              string              // MaxMind database used to annotate connection log lines
+	disabledCommands     map[string]struct{} // command names without the /, answered with msgDisabled
+	envWhitelist         map[string]struct{} // environment variables /echo env may show
+	workersWarnThreshold float64             // fraction of busy worker slots that triggers a warning, 0 to disable
+	bannerFile           string              // re-read on SIGWINCH; bannerText came from here unless it couldn't be read
+}
+
+var cfg config
+
+var serverHostname = func() string { // looked up once, for /echo hostname and /diagnostics
+	name, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return name
+}()
+
+// parseFlags reads the command line into a config. A bad value is reported as
+// an error, so that main can pick the exit code: -dry-run promises 2 for any
+// configuration problem.
+func parseFlags() (config, error) {
 	port := flag.String("port", "4000", "Port to run the TCP server on.")
 	workers := flag.String("workers", "5", "Maximum number of concurrent connections.")
+	hmacKey := flag.String("hmac-key", "", "Append an HMAC-SHA256 of each echoed message, keyed with this value.")
+	adminPassword := flag.String("admin-password", "", "Password for /auth. Admin commands are disabled when empty.")
+	auditLog := flag.String("audit-log", "", "File that admin actions are appended to as JSON lines. Defaults to audit.log in -log-dir.")
+	logDir := flag.String("log-dir", "./logs", "Directory for client and audit log files. Created if it doesn't exist.")
+	echoPrefix := flag.String("echo-prefix", "", "String to prepend to every echoed message, e.g. \"ECHO: \".")
+	echoSuffix := flag.String("echo-suffix", "", "String to append to every echoed message, e.g. \" [echoed]\".")
+	debug := flag.Bool("debug", false, "Print DEBUG-level events to the server log.")
+	quiet := flag.Bool("quiet", false, "Suppress non-error output on stdout. Errors and rejections still go to stderr.")
+	statsInterval := flag.Duration("stats-interval", 0, "Log a connection statistics summary at this interval, e.g. 60s. 0 disables it.")
+	fifoPath := flag.String("fifo", "", "Named pipe to echo messages from, e.g. /tmp/echo.pipe (Linux only). Replies are written to <path>.out.")
+	pidFile := flag.String("pid-file", "", "Write the server's PID to this file once it is listening, e.g. /var/run/echo-server.pid.")
+	maxBytesPerSession := flag.Int64("max-bytes-per-session", 0, "Disconnect a client after this many bytes in and out. 0 means unlimited.")
+	iface := flag.String("iface", "", "Network interface to listen on, e.g. eth0. Uses its first non-loopback IPv4 address.")
+	ipv6 := flag.Bool("ipv6", false, "With -iface, use the interface's IPv6 address instead of IPv4.")
+	dryRun := flag.Bool("dry-run", false, "Check that the configuration is usable, print the result and exit.")
+	httpAddr := flag.String("http", "", "Also serve POST /api/echo over HTTP on this address, e.g. :8080.")
+	eventRingSize := flag.Int("event-ring-size", 1000, "Number of recent server events kept for GET /api/events.")
+	healthAddr := flag.String("health-addr", "", "Serve /healthz, /livez and /readyz on this address, e.g. :8081.")
+	compressLogs := flag.Bool("compress-logs", false, "Write client logs gzip-compressed, as .log.gz files.")
+	flushTimeout := flag.Duration("flush-timeout", 200*time.Millisecond, "How long to keep discarding input after an oversized message.")
+	logSync := flag.Bool("log-sync", false, "fsync client logs after every message. Safer, but each message waits for the disk.")
+	netns := flag.String("netns", "", "Listen inside this network namespace, e.g. /var/run/netns/myns (Linux only).")
+	consulAddr := flag.String("consul-addr", "", "Register with the Consul agent at this address, e.g. localhost:8500.")
+	serviceName := flag.String("service-name", "echo-server", "Service name to register with Consul.")
+	noCommands := flag.Bool("no-commands", false, "Echo every message verbatim. Disables /commands and the hello and bye replies.")
+	rfc862 := flag.Bool("rfc862", false, "Strict RFC 862 echo: raw bytes, no commands, client logs or size limit. Defaults to port 7.")
+	bannerText := flag.String("banner-text", "", "Greeting sent to each client on connect. \\n starts a new line. Max 512 bytes.")
+	certFile := flag.String("cert", "", "TLS certificate file. Serve TLS when set together with -key.")
+	keyFile := flag.String("key", "", "TLS private key file for -cert.")
+	vhostConfig := flag.String("vhost-config", "", "JSON file mapping hostnames to {\"cert\", \"key\"} files, picked by SNI. Needs -cert and -key for clients without SNI.")
+	maxMessageCount := flag.Int64("max-message-count", 0, "Disconnect a client after this many messages. 0 means unlimited.")
+	pprofAddr := flag.String("pprof-addr", "", "Serve net/http/pprof profiles on this address, e.g. localhost:6060.")
+	normalize := flag.Bool("normalize", false, "Collapse each run of whitespace in echoed messages to a single space. Clients can change it with /normalize.")
+	logTimeFormat := flag.String("log-time-format", "RFC3339", "Timestamp format for log lines: RFC3339, RFC3339Nano, unix, unixmilli or a Go time layout.")
+	geoipDB := flag.String("geoip-db", "", "MaxMind GeoLite2 City or Country database (.mmdb) used to add the country and city to connection log lines.")
+	disabledCommands := flag.String("disabled-commands", "", "Comma-separated commands clients may not use, e.g. \"time,sleep,repeat\".")
+	envWhitelist := flag.String("env-whitelist", "", "Comma-separated environment variables admins may read with /echo env, e.g. \"REGION,LOG_LEVEL\". Empty allows none.")
+	workersWarnThreshold := flag.Float64("workers-warn-threshold", 0.8, "Log a warning when this fraction of -workers slots is in use, from 0 to 1. 0 disables it.")
+	bannerFile := flag.String("banner-file", "", "File holding the greeting sent on connect. Takes precedence over -banner-text, which is used if the file can't be read. Max 512 bytes. Re-read on SIGWINCH, except on Windows.")
 	flag.Parse()
 
+	fail := func(format string, args ...any) (config, error) { // keeps -dry-run visible to main
+		return config{dryRun: *dryRun}, fmt.Errorf(format, args...)
+	}
+
 	workerCount, err := strconv.Atoi(*workers)
 	if err != nil || workerCount < 1 {
-		fmt.Printf("Invalid value for -workers: %s. Must be a positive integer.\n", *workers)
-		os.Exit(1)
+		return fail("Invalid value for -workers: %s. Must be a positive integer.", *workers)
+	}
+	if *workersWarnThreshold < 0 || *workersWarnThreshold > 1 {
+		return fail("Invalid value for -workers-warn-threshold: %g. Must be between 0 and 1.", *workersWarnThreshold)
+	}
+
+	if len(*echoPrefix)+len(*echoSuffix) > maxAffixSize {
+		return fail("Invalid values for -echo-prefix and -echo-suffix: together they must be at most %d bytes.", maxAffixSize)
 	}
 
 	portStr := *port
+	if *rfc862 && !flagSet("port") {
+		portStr = "7" // the well-known echo port
+	}
+	if portStr == "" {
+		return fail("Invalid value for -port: must not be empty. Use 0 to let the OS pick a free port.")
+	}
 	if portStr[0] != ':' {
 		portStr = ":" + portStr
 	}
 
-	return portStr, workerCount
+	banner := strings.ReplaceAll(*bannerText, `\n`, "\n")
+	if len(banner) > maxBannerSize {
+		return fail("Invalid value for -banner-text: must be at most %d bytes.", maxBannerSize)
+	}
+	if *bannerFile != "" {
+		fromFile, err := loadBannerFile(*bannerFile)
+		switch {
+		case err == nil:
+			banner = fromFile // the file takes precedence over -banner-text
+		case banner == "":
+			return fail("Invalid value for -banner-file: %v", err)
+		default:
+			fmt.Fprintf(os.Stderr, "Warning: using -banner-text, cannot read -banner-file: %v\n", err)
+		}
+	}
+
+	var tlsConfig *tls.Config
+	if *certFile != "" || *keyFile != "" || *vhostConfig != "" {
+		if *certFile == "" || *keyFile == "" {
+			return fail("Invalid TLS settings: -cert and -key must be set together, and are required by -vhost-config.")
+		}
+		tlsConfig, err = loadTLSConfig(*certFile, *keyFile, *vhostConfig)
+		if err != nil {
+			return fail("Failed to load TLS certificates: %v", err)
+		}
+	}
+
+	timeFormat, err := parseLogTimeFormat(*logTimeFormat)
+	if err != nil {
+		return fail("Invalid value for -log-time-format: %v", err)
+	}
+
+	disabled := make(map[string]struct{})
+	for _, name := range strings.Split(*disabledCommands, ",") {
+		if name = strings.TrimPrefix(strings.TrimSpace(name), "/"); name != "" {
+			disabled[name] = struct{}{}
+		}
+	}
+
+	envNames := make(map[string]struct{})
+	for _, name := range strings.Split(*envWhitelist, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			envNames[name] = struct{}{}
+		}
+	}
+
+	auditLogPath := *auditLog
+	if auditLogPath == "" {
+		auditLogPath = filepath.Join(*logDir, "audit.log")
+	}
+
+	listenAddr := portStr
+	if *iface != "" {
+		ip, _, err := resolveInterfaceIP(*iface, *ipv6)
+		if err != nil {
+			return fail("Invalid value for -iface: %v", err)
+		}
+		listenAddr = net.JoinHostPort(ip.String(), portStr[1:])
+	}
+
+	return config{
+		port:       portStr,
+		listenAddr: listenAddr,
+		randomPort: portStr == ":0",
+		iface:      *iface,
+		ipv6:       *ipv6,
+		maxWorkers: workerCount,
+		hmacKey:    []byte(*hmacKey),
+
+		adminPassword: *adminPassword,
+		auditLogPath:  auditLogPath,
+		logDir:        *logDir,
+		compressLogs:  *compressLogs,
+		logSync:       *logSync,
+		echoPrefix:    *echoPrefix,
+		echoSuffix:    *echoSuffix,
+		debug:         *debug,
+		quiet:         *quiet,
+
+		statsInterval: *statsInterval,
+		fifoPath:      *fifoPath,
+		pidFile:       *pidFile,
+
+		maxBytesPerSession:   *maxBytesPerSession,
+		maxMessageCount:      max(*maxMessageCount, 0),
+		dryRun:               *dryRun,
+		flushTimeout:         *flushTimeout,
+		httpAddr:             *httpAddr,
+		eventRingSize:        max(*eventRingSize, 0),
+		healthAddr:           *healthAddr,
+		netns:                *netns,
+		consulAddr:           *consulAddr,
+		serviceName:          *serviceName,
+		noCommands:           *noCommands || *rfc862,
+		rfc862:               *rfc862,
+		bannerText:           banner,
+		tlsConfig:            tlsConfig,
+		pprofAddr:            *pprofAddr,
+		normalize:            *normalize,
+		logTimeFormat:        timeFormat,
+		geoipDB:              *geoipDB,
+		disabledCommands:     disabled,
+		envWhitelist:         envNames,
+		workersWarnThreshold: *workersWarnThreshold,
+		bannerFile:           *bannerFile,
+	}, nil
+}
+
+func parseLogTimeFormat(name string) (string, error) { // resolves a -log-time-format value to a layout
+	switch name {
+	case "RFC3339":
+		return time.RFC3339, nil
+	case "RFC3339Nano":
+		return time.RFC3339Nano, nil
+	case "unix", "unixmilli":
+		return name, nil
+	}
+
+	// A custom layout must round-trip a known time, and must contain at
+	// least one time element rather than being printed as-is.
+	known := time.Date(2009, 11, 10, 23, 0, 0, 0, time.UTC) // anything but the reference time itself
+	formatted := known.Format(name)
+	if formatted == name {
+		return "", fmt.Errorf("%q has no time elements", name)
+	}
+	if _, err := time.Parse(name, formatted); err != nil {
+		return "", fmt.Errorf("%q is not a usable time layout: %v", name, err)
+	}
+	return name, nil
+}
+
+func logTimestamp() string { // the current time for log lines, in -log-time-format
+	now := time.Now()
+	switch cfg.logTimeFormat {
+	case "unix":
+		return strconv.FormatInt(now.Unix(), 10)
+	case "unixmilli":
+		return strconv.FormatInt(now.UnixMilli(), 10)
+	case "":
+		return now.Format(time.RFC3339) // cfg not parsed yet
+	default:
+		return now.Format(cfg.logTimeFormat)
+	}
+}
+
+func flagSet(name string) bool { // reports whether the flag was given on the command line
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func signMessage(key []byte, message string) string { // appends " HMAC=<hex>" when a key is set
+	if len(key) == 0 {
+		return message
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return message + " HMAC=" + hex.EncodeToString(mac.Sum(nil))
 }
 
 func flushExtraInput(conn net.Conn, buf []byte, maxMessageSize int) error {
-	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	// The deadline is set once, so even a client that never stops sending
+	// can't keep us here for longer than -flush-timeout.
+	conn.SetReadDeadline(time.Now().Add(cfg.flushTimeout))
 	for {
 		n, err := conn.Read(buf)
 		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 			return nil // done flushing
 		}
 		if err != nil {
-			return err
+			return fmt.Errorf("flush after overflow: %w", err)
 		}
 		if n < maxMessageSize {
 			return nil // no more overflow
@@ -150,14 +547,19 @@ func flushExtraInput(conn net.Conn, buf []byte, maxMessageSize int) error {
 
 type clientLogger struct { // clientLogger object, so we can attach methods to it
 	file *os.File
+	gz   *gzip.Writer // wraps file when -compress-logs is set
+	sync bool         // fsync after every line, -log-sync
 	ip   string
 }
 
-func newClientLogger(conn net.Conn) (*clientLogger, error) { // creates a file to log messages in
+func newClientLogger(conn net.Conn, logDir string, compress, syncWrites bool) (*clientLogger, error) { // creates a file to log messages in
 	// Use full address (IP:Port), but change ":" to "_"
 	rawAddr := conn.RemoteAddr().String()
 	safeAddr := strings.ReplaceAll(rawAddr, ":", "_")
-	logFilePath := fmt.Sprintf("logs/client_%s.log", safeAddr)
+	logFilePath := filepath.Join(logDir, fmt.Sprintf("client_%s.log", safeAddr))
+	if compress {
+		logFilePath += ".gz"
+	}
 
 	file, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	// opens file
@@ -165,45 +567,304 @@ func newClientLogger(conn net.Conn) (*clientLogger, error) { // creates a file t
 		return nil, err
 	}
 	// returns file object to write to
-	return &clientLogger{file: file, ip: rawAddr}, nil
+	cl := &clientLogger{file: file, sync: syncWrites, ip: rawAddr}
+	if compress {
+		// Appending adds a new gzip member, which gzip readers treat as one stream
+		cl.gz, _ = gzip.NewWriterLevel(file, gzip.BestSpeed)
+	}
+	return cl, nil
+}
+
+func checkWritable(dir string) error { // writes and removes a temp file to prove we can log to dir
+	file, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return err
+	}
+	name := file.Name()
+	file.Close()
+	return os.Remove(name)
 }
 
 func (cl *clientLogger) Log(message string) error { // Adds a method to the client Logger object
-	timestamp := time.Now().Format(time.RFC3339)
-	_, err := cl.file.WriteString(fmt.Sprintf("[%s] %s\n", timestamp, message)) // writing file
-	return err
+	timestamp := logTimestamp()
+	line := fmt.Sprintf("[%s] %s\n", timestamp, message)
+	if cl.gz != nil {
+		if _, err := cl.gz.Write([]byte(line)); err != nil {
+			return err
+		}
+		if err := cl.gz.Flush(); err != nil { // flush every line so a crash loses at most the current one
+			return err
+		}
+	} else if _, err := cl.file.WriteString(line); err != nil { // writing file
+		return err
+	}
+
+	if cl.sync {
+		return cl.file.Sync()
+	}
+	return nil
 }
 
 func (cl *clientLogger) Close() {
+	if cl.gz != nil {
+		cl.gz.Close()
+	}
 	cl.file.Close()
 }
 func logRejection(conn net.Conn) {
 	address := conn.RemoteAddr().String()
-	timestamp := time.Now().Format(time.RFC3339)
-	fmt.Printf("[%s] Rejected connection from %s (max connections reached)\n", timestamp, address)
+	timestamp := logTimestamp()
+	fmt.Fprintf(os.Stderr, "[%s] Rejected connection from %s (max connections reached)\n", timestamp, address)
+	stats.recordRejection()
+	events.Push("rejection", address)
+}
+
+func listen(cfg config) (net.Listener, error) { // the TCP listener, inside -netns and wrapped in TLS when set
+	var listener net.Listener
+	var err error
+	if cfg.netns != "" {
+		listener, err = listenInNetns(cfg.netns, cfg.listenAddr)
+	} else {
+		listener, err = net.Listen("tcp", cfg.listenAddr)
+	}
+	if err != nil || cfg.tlsConfig == nil {
+		return listener, err
+	}
+	return tls.NewListener(listener, cfg.tlsConfig), nil
+}
+
+func checkConfig(cfg config) error { // does everything startup would do, then undoes it
+	if err := os.MkdirAll(cfg.logDir, 0755); err != nil {
+		return fmt.Errorf("log directory %s: %v", cfg.logDir, err)
+	}
+	if err := checkWritable(cfg.logDir); err != nil {
+		return fmt.Errorf("log directory %s is not writable: %v", cfg.logDir, err)
+	}
+
+	listener, err := listen(cfg) // Go sets SO_REUSEADDR on Unix listeners
+	if err != nil {
+		return fmt.Errorf("cannot listen on %s: %v", cfg.listenAddr, err)
+	}
+	listener.Close()
+
+	if cfg.geoipDB != "" {
+		db, err := openGeoDB(cfg.geoipDB)
+		if err != nil {
+			return err
+		}
+		db.Close()
+	}
+
+	if cfg.adminPassword != "" {
+		al, err := newAuditLogger(cfg.auditLogPath)
+		if err != nil {
+			return fmt.Errorf("audit log %s: %v", cfg.auditLogPath, err)
+		}
+		al.Close()
+	}
+	return nil
 }
 
 func main() {
-	port, maxWorkers := parseFlags() // -port flag, default value of 4000
-	listener, err := net.Listen("tcp", port)
+	var err error
+	cfg, err = parseFlags() // -port flag, default value of 4000
+	if err != nil {
+		if cfg.dryRun {
+			fmt.Println("Configuration error:", err)
+			os.Exit(2)
+		}
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	events = newEventRing(cfg.eventRingSize)
+
+	if cfg.dryRun {
+		if err := checkConfig(cfg); err != nil {
+			fmt.Println("Configuration error:", err)
+			os.Exit(2)
+		}
+		fmt.Println("Configuration OK.")
+		os.Exit(0)
+	}
+
+	// Logging is a core function, so a missing log directory is fatal
+	if err := os.MkdirAll(cfg.logDir, 0755); err != nil {
+		fmt.Printf("Failed to create log directory %s: %v\n", cfg.logDir, err)
+		os.Exit(1)
+	}
+	if err := checkWritable(cfg.logDir); err != nil {
+		fmt.Printf("Log directory %s is not writable: %v\n", cfg.logDir, err)
+		os.Exit(1)
+	}
+
+	port, maxWorkers := cfg.listenAddr, cfg.maxWorkers
+	echoPrefix.Store(cfg.echoPrefix)
+	echoSuffix.Store(cfg.echoSuffix)
+	if cfg.port == ":7" && os.Geteuid() > 0 { // Geteuid is -1 on Windows, where low ports are open to everyone
+		fmt.Fprintln(os.Stderr, "Warning: port 7 is privileged; listening will fail unless running as root or with CAP_NET_BIND_SERVICE.")
+	}
+	listener, err := listen(cfg)
 	if err != nil {
 		panic(err)
 	}
 	defer listener.Close()
 
+	if cfg.randomPort { // report the port the OS picked
+		host, _, _ := net.SplitHostPort(port)
+		_, assigned, _ := net.SplitHostPort(listener.Addr().String())
+		port = net.JoinHostPort(host, assigned) + " (assigned)"
+	}
+
+	if cfg.iface != "" {
+		if _, iface, err := resolveInterfaceIP(cfg.iface, cfg.ipv6); err == nil {
+			go watchInterface(iface)
+		}
+	}
+
+	if cfg.geoipDB != "" {
+		geoDB, err = openGeoDB(cfg.geoipDB)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer geoDB.Close()
+	}
+
+	if cfg.adminPassword != "" {
+		audit, err = newAuditLogger(cfg.auditLogPath)
+		if err != nil {
+			fmt.Printf("Failed to open audit log %s: %v\n", cfg.auditLogPath, err)
+			os.Exit(1)
+		}
+		defer audit.Close()
+	}
+
 	workerPool := make(chan struct{}, maxWorkers)
 	var wg sync.WaitGroup
 
-	fmt.Printf("Server listening on %s (max %d concurrent clients)\n", port, maxWorkers)
+	if !cfg.quiet {
+		fmt.Printf("Server listening on %s (max %d concurrent clients)\n", port, maxWorkers)
+	}
+
+	if cfg.pprofAddr != "" {
+		pprofServer := startPprof(cfg.pprofAddr)
+		defer stopPprof(pprofServer)
+		if !cfg.quiet {
+			fmt.Printf("pprof available at http://%s/debug/pprof/\n", cfg.pprofAddr)
+		}
+	}
+
+	if cfg.httpAddr != "" {
+		go serveHTTP(cfg.httpAddr)
+	}
+
+	if cfg.healthAddr != "" {
+		go serveHealth(cfg.healthAddr, workerPool)
+	}
+
+	if cfg.statsInterval > 0 && !cfg.quiet {
+		go logStatsPeriodically(cfg.statsInterval)
+	}
+
+	if cfg.workersWarnThreshold > 0 {
+		go watchWorkerPool(workerPool, cfg.workersWarnThreshold)
+	}
+
+	if cfg.bannerFile != "" && len(reloadSignals) > 0 {
+		go watchBannerReloads()
+	}
+
+	if cfg.fifoPath != "" {
+		in, err := openFIFO(cfg.fifoPath)
+		if err != nil {
+			fmt.Printf("Failed to open FIFO %s: %v\n", cfg.fifoPath, err)
+			os.Exit(1)
+		}
+		out, err := openFIFO(cfg.fifoPath + ".out") // separate pipe so we never read back our own replies
+		if err != nil {
+			fmt.Printf("Failed to open FIFO %s.out: %v\n", cfg.fifoPath, err)
+			os.Exit(1)
+		}
+		if !cfg.quiet {
+			fmt.Printf("Echoing FIFO %s to %s.out\n", cfg.fifoPath, cfg.fifoPath)
+		}
+		go serveFIFO(in, out)
+	}
+
+	if cfg.pidFile != "" {
+		if err := writePIDFile(cfg.pidFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not write PID file %s: %v\n", cfg.pidFile, err)
+		} else {
+			defer os.Remove(cfg.pidFile)
+		}
+	}
 
+	if err := sdNotify("READY=1\n"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: sd_notify failed: %v\n", err)
+	}
+	if interval := watchdogInterval(); interval > 0 {
+		go runWatchdog(interval)
+	}
+
+	var consulID string
+	if cfg.consulAddr != "" {
+		// Discovery is optional, so an unreachable agent doesn't stop the server
+		consulID, err = registerConsul(cfg.consulAddr, cfg.serviceName, listener.Addr())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not register with Consul at %s: %v\n", cfg.consulAddr, err)
+		} else if !cfg.quiet {
+			fmt.Printf("Registered with Consul as %s\n", consulID)
+		}
+	}
+
+	done := make(chan struct{})  // closed when the server should stop accepting
+	force := make(chan struct{}) // closed by a shutdown signal during a drain, so we stop waiting for sessions
+	stopAccepting := sync.OnceFunc(func() {
+		shuttingDown.Store(true) // /readyz fails before we stop accepting
+		close(done)
+		listener.Close() // unblocks Accept
+	})
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		signal.Stop(signals) // a second signal kills the process the usual way
+		if draining.Load() {
+			close(force)
+		}
+		stopAccepting()
+	}()
+
+	if len(drainSignals) > 0 {
+		drain := make(chan os.Signal, 1)
+		signal.Notify(drain, drainSignals...) // stays registered, so repeats are swallowed rather than killing us
+		go func() {
+			<-drain
+			draining.Store(true)
+			stopAccepting()
+		}()
+	}
+
+acceptLoop:
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
+			select {
+			case <-done:
+				break acceptLoop
+			default:
+			}
 			fmt.Println("Error accepting:", err)
 			continue
 		}
 
 		select {
+		case <-done:
+			conn.Close()
+			break acceptLoop
+
 		case workerPool <- struct{}{}: // Try to acquire a slot
 			wg.Add(1)
 			go worker(conn, &wg, workerPool)
@@ -215,5 +876,31 @@ func main() {
 		}
 	}
 
-	wg.Wait() // unreachable
+	sdNotify("STOPPING=1\n")
+	if consulID != "" {
+		if err := deregisterConsul(cfg.consulAddr, consulID); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not deregister from Consul: %v\n", err)
+		}
+	}
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	if !cfg.quiet {
+		if draining.Load() {
+			go logDraining(finished)
+		} else {
+			fmt.Printf("Shutting down, waiting for %d active connections to finish\n", stats.activeConnections.Load())
+		}
+	}
+
+	select {
+	case <-finished:
+	case <-force:
+		if !cfg.quiet {
+			fmt.Printf("Shutting down with %d sessions still active\n", stats.activeConnections.Load())
+		}
+	}
 }
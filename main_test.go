@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func useTestConfig(t *testing.T) { // a quiet config logging to a temp dir, restored when the test ends
+	t.Helper()
+	saved := cfg
+	t.Cleanup(func() { cfg = saved })
+	cfg = config{logDir: t.TempDir(), quiet: true, flushTimeout: time.Second}
+	echoPrefix.Store("")
+	echoSuffix.Store("")
+}
+
+func captureStderr(t *testing.T, f func()) string { // runs f and returns what it wrote to os.Stderr
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = saved }()
+
+	output := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		output <- string(b)
+	}()
+	f()
+	w.Close()
+	return <-output
+}
+
+func serveTestConn(t *testing.T, client func(conn net.Conn)) string { // runs handleConnection against client, returns the stderr log
+	t.Helper()
+	serverConn, clientConn := net.Pipe()
+	return captureStderr(t, func() {
+		done := make(chan struct{})
+		go func() {
+			handleConnection(serverConn)
+			close(done)
+		}()
+		client(clientConn)
+		clientConn.Close()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("handleConnection did not return")
+		}
+	})
+}
+
+func TestFlushCloseReachesLogError(t *testing.T) {
+	useTestConfig(t)
+	stderr := serveTestConn(t, func(conn net.Conn) {
+		conn.Write([]byte(strings.Repeat("x", maxMessageSize))) // fills the buffer, so the server starts flushing
+		reply := make([]byte, 256)
+		conn.Read(reply) // the "too long" notice; the close below then lands mid-flush
+	})
+
+	if !strings.Contains(stderr, "flush after overflow: EOF") {
+		t.Errorf("log does not show the wrapped flush error:\n%s", stderr)
+	}
+}
+
+func TestTimeoutLoggedOnce(t *testing.T) {
+	useTestConfig(t)
+	serverConn, clientConn := net.Pipe()
+	serverConn.SetReadDeadline(time.Now().Add(-time.Second))
+	_, err := serverConn.Read(make([]byte, 1))
+	if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+		t.Fatalf("Read past the deadline = %v, want a timeout", err)
+	}
+	clientConn.Close() // so logError's notice to the client doesn't block on the pipe
+
+	stderr := captureStderr(t, func() { logError(serverConn, err) })
+
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], "Timeout: Client pipe") {
+		t.Errorf("want exactly one timeout line, got %d:\n%s", len(lines), stderr)
+	}
+	if strings.Contains(stderr, err.Error()) {
+		t.Errorf("raw error printed as well as the timeout line:\n%s", stderr)
+	}
+}
+
+func TestRFC862TimeoutWritesNothing(t *testing.T) {
+	useTestConfig(t)
+	cfg.rfc862 = true
+	serverConn, _ := net.Pipe()
+	serverConn.SetReadDeadline(time.Now().Add(-time.Second))
+	_, err := serverConn.Read(make([]byte, 1))
+
+	conn := &httpConn{remote: "192.0.2.1:7"}
+	stderr := captureStderr(t, func() { logError(conn, err) })
+
+	if conn.String() != "" {
+		t.Errorf("timeout wrote %q into the RFC 862 stream", conn.String())
+	}
+	if want := "inactive for " + rfc862IdleTimeout.String(); !strings.Contains(stderr, want) {
+		t.Errorf("log does not say %q:\n%s", want, stderr)
+	}
+}
+
+func TestByeIsNotLoggedAsError(t *testing.T) {
+	useTestConfig(t)
+	errorsBefore := stats.errors.Load()
+	var reply []byte
+	stderr := serveTestConn(t, func(conn net.Conn) {
+		conn.Write([]byte("bye\n"))
+		reply, _ = io.ReadAll(conn) // the server hangs up after saying goodbye
+	})
+
+	if string(reply) != msgGoodbye+"\n" {
+		t.Errorf("reply = %q, want %q", reply, msgGoodbye+"\n")
+	}
+	if stderr != "" {
+		t.Errorf("bye produced log output:\n%s", stderr)
+	}
+	if stats.errors.Load() != errorsBefore {
+		t.Error("bye was counted as an error")
+	}
+}
+
+// BenchmarkFlushExtraInput times how long the server spends discarding the
+// rest of an oversized message. A client that sends a long line and then
+// stops is flushed as fast as the connection delivers it: the flush ends at
+// the first short read, whatever the size. Only a client that never pauses
+// keeps the flush going, and -flush-timeout caps that ("endless" case),
+// since the deadline is set once and not extended by each read.
+func BenchmarkFlushExtraInput(b *testing.B) {
+	saved := cfg
+	b.Cleanup(func() { cfg = saved })
+	cfg.flushTimeout = 200 * time.Millisecond
+
+	for _, size := range []int{1 << 10, 64 << 10, 1 << 20} {
+		payload := append([]byte(strings.Repeat("x", size)), '\n')
+		b.Run(fmt.Sprintf("%dKB", size>>10), func(b *testing.B) {
+			b.SetBytes(int64(len(payload)))
+			buf := make([]byte, maxMessageSize)
+			for i := 0; i < b.N; i++ {
+				serverConn, clientConn := net.Pipe()
+				go clientConn.Write(payload)
+				if err := flushExtraInput(serverConn, buf, maxMessageSize); err != nil {
+					b.Fatal(err)
+				}
+				clientConn.Close()
+				serverConn.Close()
+			}
+		})
+	}
+
+	b.Run("endless", func(b *testing.B) {
+		chunk := []byte(strings.Repeat("x", maxMessageSize))
+		buf := make([]byte, maxMessageSize)
+		for i := 0; i < b.N; i++ {
+			serverConn, clientConn := net.Pipe()
+			go func() {
+				for {
+					if _, err := clientConn.Write(chunk); err != nil {
+						return // closed once the flush gave up
+					}
+				}
+			}()
+			start := time.Now()
+			if err := flushExtraInput(serverConn, buf, maxMessageSize); err != nil {
+				b.Fatal(err)
+			}
+			if elapsed := time.Since(start); elapsed > 2*cfg.flushTimeout {
+				b.Fatalf("flush ran for %s, want about %s", elapsed, cfg.flushTimeout)
+			}
+			clientConn.Close()
+			serverConn.Close()
+		}
+	})
+}
+
+// BenchmarkClientLoggerLog compares client log throughput with and without
+// -log-sync, to show what the fsync after every line costs.
+func BenchmarkClientLoggerLog(b *testing.B) {
+	message := strings.Repeat("x", 64)
+	for _, bc := range []struct {
+		name           string
+		compress, sync bool
+	}{
+		{"plain", false, false},
+		{"sync", false, true},
+		{"gzip", true, false},
+		{"gzip+sync", true, true},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			logger, err := newClientLogger(&httpConn{remote: "bench"}, b.TempDir(), bc.compress, bc.sync)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer logger.Close()
+
+			b.SetBytes(int64(len(message)))
+			for i := 0; i < b.N; i++ {
+				if err := logger.Log(message); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
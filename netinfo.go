@@ -0,0 +1,56 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+)
+
+type socketInfo struct {
+	keepAlive bool
+	noDelay   bool
+	rcvBuf    int // bytes, as reported by the kernel
+	sndBuf    int
+}
+
+func handleNetinfo(session *clientSession) error {
+	conn := session.conn
+	lines := []string{
+		"Local address:  " + conn.LocalAddr().String(),
+		"Remote address: " + conn.RemoteAddr().String(),
+	}
+
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		state := tlsConn.ConnectionState()
+		lines = append(lines, fmt.Sprintf("TLS:            %s, %s", tls.VersionName(state.Version), tls.CipherSuiteName(state.CipherSuite)))
+		conn = tlsConn.NetConn()
+	} else {
+		lines = append(lines, "TLS:            off")
+	}
+
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		lines = append(lines, "Socket options: not a TCP connection")
+		return writeLine(session, strings.Join(lines, "\n"))
+	}
+	info, err := readSocketInfo(tcpConn)
+	if err != nil {
+		lines = append(lines, fmt.Sprintf("Socket options: %v", err))
+		return writeLine(session, strings.Join(lines, "\n"))
+	}
+	lines = append(lines,
+		fmt.Sprintf("TCP keepalive:  %s", onOff(info.keepAlive)),
+		fmt.Sprintf("TCP nodelay:    %s", onOff(info.noDelay)),
+		fmt.Sprintf("Receive buffer: %d bytes", info.rcvBuf),
+		fmt.Sprintf("Send buffer:    %d bytes", info.sndBuf),
+	)
+	return writeLine(session, strings.Join(lines, "\n"))
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
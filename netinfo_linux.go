@@ -0,0 +1,35 @@
+//go:build linux
+
+package main
+
+import (
+	"net"
+	"syscall"
+)
+
+func readSocketInfo(conn *net.TCPConn) (socketInfo, error) { // reads the socket options straight from the kernel
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return socketInfo{}, err
+	}
+
+	var info socketInfo
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		get := func(level, opt int) int {
+			v, err := syscall.GetsockoptInt(int(fd), level, opt)
+			if err != nil && sockErr == nil {
+				sockErr = err
+			}
+			return v
+		}
+		info.keepAlive = get(syscall.SOL_SOCKET, syscall.SO_KEEPALIVE) != 0
+		info.noDelay = get(syscall.IPPROTO_TCP, syscall.TCP_NODELAY) != 0
+		info.rcvBuf = get(syscall.SOL_SOCKET, syscall.SO_RCVBUF)
+		info.sndBuf = get(syscall.SOL_SOCKET, syscall.SO_SNDBUF)
+	})
+	if err != nil {
+		return socketInfo{}, err
+	}
+	return info, sockErr
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+func readSocketInfo(conn *net.TCPConn) (socketInfo, error) {
+	return socketInfo{}, errors.New("only available on Linux")
+}
@@ -0,0 +1,60 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+func listenInNetns(path, addr string) (net.Listener, error) { // opens a TCP listener inside the network namespace at path
+	type result struct {
+		listener net.Listener
+		err      error
+	}
+	done := make(chan result, 1)
+
+	go func() {
+		// setns only affects the calling thread, so pin this goroutine to it
+		// while we switch namespaces and back.
+		runtime.LockOSThread()
+
+		orig, err := os.Open("/proc/thread-self/ns/net")
+		if err != nil {
+			runtime.UnlockOSThread()
+			done <- result{err: fmt.Errorf("current network namespace: %v", err)}
+			return
+		}
+		defer orig.Close()
+
+		target, err := os.Open(path)
+		if err != nil {
+			runtime.UnlockOSThread()
+			done <- result{err: err}
+			return
+		}
+		defer target.Close()
+
+		if err := unix.Setns(int(target.Fd()), unix.CLONE_NEWNET); err != nil {
+			runtime.UnlockOSThread()
+			done <- result{err: fmt.Errorf("setns %s: %v", path, err)}
+			return
+		}
+
+		listener, err := net.Listen("tcp", addr) // the socket stays in the namespace it was created in
+
+		// If we can't get back, leave the thread locked so the runtime
+		// throws it away instead of reusing it in the wrong namespace.
+		if unix.Setns(int(orig.Fd()), unix.CLONE_NEWNET) == nil {
+			runtime.UnlockOSThread()
+		}
+		done <- result{listener, err}
+	}()
+
+	r := <-done
+	return r.listener, r.err
+}
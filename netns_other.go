@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+func listenInNetns(path, addr string) (net.Listener, error) {
+	return nil, errors.New("-netns is only supported on Linux")
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	poolWatchInterval = time.Second
+	poolWarnEvery     = time.Minute // at most one warning per minute while the pool stays busy
+)
+
+func watchWorkerPool(pool chan struct{}, threshold float64) { // warns when the share of busy slots reaches threshold, runs forever
+	ticker := time.NewTicker(poolWatchInterval)
+	defer ticker.Stop()
+
+	var lastWarning time.Time
+	for range ticker.C {
+		busy, slots := len(pool), cap(pool)
+		pressure := float64(busy) / float64(slots)
+		if pressure < threshold || time.Since(lastWarning) < poolWarnEvery {
+			continue
+		}
+		lastWarning = time.Now()
+		fmt.Fprintf(os.Stderr, "[%s] Warning: Worker pool at %.0f%% capacity (%d/%d slots).\n",
+			logTimestamp(), pressure*100, busy, slots)
+	}
+}
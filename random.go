@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxRandString = 128
+	alphanumeric  = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+const randUsage = "Usage: /echo rand int [min] [max] | float | string <n> | uuid"
+
+func handleEchoRand(session *clientSession, args string) error { // every value comes from crypto/rand
+	kind, rest := splitArg(args)
+	switch kind {
+	case "int":
+		lo, hi := int64(0), int64(math.MaxInt64)
+		fields := strings.Fields(rest)
+		var err error
+		switch len(fields) {
+		case 0:
+		case 1:
+			hi, err = strconv.ParseInt(fields[0], 10, 64)
+		case 2:
+			lo, err = strconv.ParseInt(fields[0], 10, 64)
+			if err == nil {
+				hi, err = strconv.ParseInt(fields[1], 10, 64)
+			}
+		default:
+			return writeLine(session, randUsage)
+		}
+		if err != nil || lo > hi {
+			return writeLine(session, "Usage: /echo rand int [min] [max], with min <= max")
+		}
+		return writeLine(session, strconv.FormatInt(randInt(lo, hi), 10))
+	case "float":
+		return writeLine(session, strconv.FormatFloat(randFloat(), 'f', -1, 64))
+	case "string":
+		n, err := strconv.Atoi(rest)
+		if err != nil || n < 1 || n > maxRandString {
+			return writeLine(session, fmt.Sprintf("Usage: /echo rand string <n>, n between 1 and %d", maxRandString))
+		}
+		return writeLine(session, randString(n))
+	case "uuid":
+		return handleEchoUUID(session, rest)
+	default:
+		return writeLine(session, randUsage)
+	}
+}
+
+func randInt(lo, hi int64) int64 { // uniform in [lo, hi]
+	span := new(big.Int).Sub(big.NewInt(hi), big.NewInt(lo))
+	span.Add(span, big.NewInt(1)) // may exceed int64, which is why this is a big.Int
+	n, _ := rand.Int(rand.Reader, span)
+	return n.Add(n, big.NewInt(lo)).Int64()
+}
+
+func randFloat() float64 { // uniform in [0, 1), from the top 53 bits of a random uint64
+	var b [8]byte
+	rand.Read(b[:])
+	return float64(binary.LittleEndian.Uint64(b[:])>>11) / (1 << 53)
+}
+
+func randString(n int) string {
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = alphanumeric[randInt(0, int64(len(alphanumeric)-1))]
+	}
+	return string(out)
+}
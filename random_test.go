@@ -0,0 +1,74 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRandInt(t *testing.T) {
+	ranges := []struct{ lo, hi int64 }{
+		{0, 0},
+		{5, 5},
+		{0, 1},
+		{-5, -1},
+		{-3, 3},
+		{math.MaxInt64 - 2, math.MaxInt64},
+		{math.MinInt64, math.MinInt64 + 2},
+		{math.MinInt64, math.MaxInt64}, // the span overflows int64
+	}
+	for _, r := range ranges {
+		for i := 0; i < 200; i++ {
+			if n := randInt(r.lo, r.hi); n < r.lo || n > r.hi {
+				t.Fatalf("randInt(%d, %d) = %d, out of range", r.lo, r.hi, n)
+			}
+		}
+	}
+
+	seen := make(map[int64]bool) // both ends are inclusive
+	for i := 0; i < 1000 && len(seen) < 3; i++ {
+		seen[randInt(1, 3)] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("randInt(1, 3) only returned %v in 1000 tries", seen)
+	}
+}
+
+func TestRandFloat(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		if f := randFloat(); f < 0 || f >= 1 {
+			t.Fatalf("randFloat() = %v, want [0, 1)", f)
+		}
+	}
+}
+
+func TestRandString(t *testing.T) {
+	for _, n := range []int{1, 16, maxRandString} {
+		s := randString(n)
+		if len(s) != n {
+			t.Errorf("randString(%d) has length %d", n, len(s))
+		}
+		if i := strings.IndexFunc(s, func(r rune) bool { return !strings.ContainsRune(alphanumeric, r) }); i >= 0 {
+			t.Errorf("randString(%d) = %q, %q is not alphanumeric", n, s, s[i])
+		}
+	}
+}
+
+func TestEchoRandReplies(t *testing.T) {
+	useTestConfig(t)
+	for i := 0; i < 50; i++ {
+		reply := strings.TrimSuffix(runCommand(t, "/echo rand int -5 -1"), "\n")
+		if n, err := strconv.Atoi(reply); err != nil || n < -5 || n > -1 {
+			t.Fatalf("/echo rand int -5 -1 replied %q", reply)
+		}
+	}
+	for _, bad := range []string{"/echo rand int 5 1", "/echo rand int x", "/echo rand string 0", "/echo rand string 129"} {
+		if reply := runCommand(t, bad); !strings.HasPrefix(reply, "Usage:") {
+			t.Errorf("%q replied %q, want usage", bad, reply)
+		}
+	}
+	if reply := strings.TrimSuffix(runCommand(t, "/echo rand uuid"), "\n"); len(reply) != 36 || reply[14] != '4' {
+		t.Errorf("/echo rand uuid replied %q, want a version 4 UUID", reply)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// SIGWINCH means "terminal resized", which never matters to a server with no
+// terminal, so it is reused to reload -banner-file without a restart:
+//
+//	kill -WINCH <pid>
+var reloadSignals = []os.Signal{syscall.SIGWINCH}
@@ -0,0 +1,7 @@
+//go:build windows
+
+package main
+
+import "os"
+
+var reloadSignals []os.Signal // Windows has no SIGWINCH, so -banner-file is only read at startup
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+const rfc862IdleTimeout = time.Hour // RFC 862 has no timeout; this only stops dead peers holding a slot forever
+
+func handleRFC862(conn net.Conn) error { // echoes every octet back unchanged, no framing, commands or client log
+	buf := make([]byte, 32*1024)
+	for {
+		conn.SetReadDeadline(time.Now().Add(rfc862IdleTimeout))
+		n, err := conn.Read(buf)
+		if n > 0 {
+			stats.recordMessage(n)
+			if _, werr := conn.Write(buf[:n]); werr != nil {
+				return werr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
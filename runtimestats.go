@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const cpuStatsInterval = 5 * time.Second // ReadMemStats stops the world, so don't let a client loop on it
+
+func handleEchoCPU(session *clientSession) error {
+	if !requireAdmin(session) {
+		return nil
+	}
+	if wait := cpuStatsInterval - time.Since(session.lastCPUStats); wait > 0 {
+		return writeLine(session, fmt.Sprintf("/echo cpu can run once every %s. Try again in %s.", cpuStatsInterval, wait.Round(100*time.Millisecond)))
+	}
+	session.lastCPUStats = time.Now()
+
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return writeLine(session, fmt.Sprintf("goroutines=%d heap_alloc=%d heap_sys=%d num_gc=%d pause_total_ns=%d",
+		runtime.NumGoroutine(), m.HeapAlloc, m.HeapSys, m.NumGC, m.PauseTotalNs))
+}
+
+const gcInterval = 30 * time.Second // server-wide, since a forced GC affects every client
+
+var (
+	gcMu   sync.Mutex
+	lastGC time.Time // when /echo gc last ran
+)
+
+func handleEchoGC(session *clientSession) error {
+	if !requireAdmin(session) {
+		return nil
+	}
+
+	gcMu.Lock()
+	if wait := gcInterval - time.Since(lastGC); wait > 0 {
+		gcMu.Unlock()
+		return writeLine(session, fmt.Sprintf("/echo gc can run once every %s. Try again in %s.", gcInterval, wait.Round(time.Second)))
+	}
+	lastGC = time.Now()
+	gcMu.Unlock()
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+
+	freed := int64(before.HeapAlloc) - int64(after.HeapAlloc)
+	auditAction(session, "gc", fmt.Sprintf("heap_alloc_before=%d heap_alloc_after=%d", before.HeapAlloc, after.HeapAlloc), true)
+	return writeLine(session, fmt.Sprintf("GC triggered. HeapAlloc: %d → %d bytes. Freed: %d bytes.", before.HeapAlloc, after.HeapAlloc, freed))
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type clientSession struct { // per-connection state shared by the command handlers
+	id          int64
+	conn        net.Conn
+	ctx         context.Context // cancelled when the connection ends
+	cancel      context.CancelFunc
+	connectedAt time.Time
+	isAdmin     bool
+	format      string            // name of the /format mode applied to echoes, "" for none
+	cmdHistory  []string          // /commands sent this session, oldest first
+	vars        map[string]string // values stored with /setvar
+	hmacKey     []byte            // starts as -hmac-key, replaced by /rekey
+	msgFreq     map[string]int    // times each plain message was sent, see countMessage
+	timerStart  time.Time         // connectedAt until an admin runs /timer reset
+	laps        []lapEntry        // recorded with /timer lap, oldest first
+	logger      *clientLogger     // the client log, nil for sessions that don't have one
+	normalize   bool              // collapse whitespace in echoes; starts as -normalize, toggled with /normalize
+
+	lastCPUStats time.Time // when /echo cpu last ran, for its rate limit
+	templateSeq  int64     // /echo template renders this session, exposed as .Seq
+
+	msgCount atomic.Int64 // messages received from the client
+	bytesIn  atomic.Int64 // bytes read from the client
+	bytesOut atomic.Int64 // bytes written to the client
+}
+
+type lapEntry struct {
+	label string
+	at    time.Time
+	split time.Duration // time since the previous lap, or since the timer started
+}
+
+var lastSessionID atomic.Int64
+
+func newClientSession(conn net.Conn) *clientSession {
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	return &clientSession{
+		id:          lastSessionID.Add(1),
+		conn:        conn,
+		ctx:         ctx,
+		cancel:      cancel,
+		connectedAt: now,
+		timerStart:  now,
+		vars:        make(map[string]string),
+		msgFreq:     make(map[string]int),
+		hmacKey:     cfg.hmacKey,
+		normalize:   cfg.normalize,
+	}
+}
+
+func (s *clientSession) Close() { // stops any background work started by the session
+	s.cancel()
+}
+
+func (s *clientSession) log(message string) { // adds a line to the client log, if there is one
+	if s.logger != nil {
+		s.logger.Log(message)
+	}
+}
+
+func (s *clientSession) Write(p []byte) (int, error) { // writes to the client, counting bytes sent
+	n, err := s.conn.Write(p)
+	s.bytesOut.Add(int64(n))
+	return n, err
+}
+
+const maxCmdHistory = 100
+
+func (s *clientSession) recordCommand(cmd string) {
+	if strings.HasPrefix(cmd, "/auth") {
+		return // keep the admin password out of /history
+	}
+	s.cmdHistory = append(s.cmdHistory, cmd)
+	if len(s.cmdHistory) > maxCmdHistory {
+		s.cmdHistory = s.cmdHistory[1:]
+	}
+}
+
+func (s *clientSession) recallCommand(ref string) (string, bool) { // resolves "/!!" or "/!<n>" to a past command
+	if len(s.cmdHistory) == 0 {
+		return "", false
+	}
+	if ref == "/!!" {
+		return s.cmdHistory[len(s.cmdHistory)-1], true
+	}
+	n, err := strconv.Atoi(ref[len("/!"):])
+	if err != nil || n < 1 || n > len(s.cmdHistory) {
+		return "", false
+	}
+	return s.cmdHistory[n-1], true
+}
+
+const (
+	maxMsgFreqKeys = 1000
+	otherMessages  = "other" // bucket for new messages once msgFreq is full
+)
+
+func (s *clientSession) countMessage(message string) {
+	if _, seen := s.msgFreq[message]; !seen && len(s.msgFreq) >= maxMsgFreqKeys {
+		message = otherMessages
+	}
+	s.msgFreq[message]++
+}
+
+func (s *clientSession) authenticate(password string) bool { // grants admin rights if the password matches -admin-password
+	if cfg.adminPassword == "" {
+		return false // admin features are disabled
+	}
+	if subtle.ConstantTimeCompare([]byte(password), []byte(cfg.adminPassword)) != 1 {
+		auditAction(s, "auth", "", false)
+		return false
+	}
+	s.isAdmin = true
+	auditAction(s, "auth", "", true)
+	return true
+}
+
+func requireAdmin(session *clientSession) bool { // tells the client off if the session is not an admin
+	if session.isAdmin {
+		return true
+	}
+	writeLine(session, msgAdminOnly)
+	return false
+}
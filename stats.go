@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type serverStats struct { // server-wide counters, safe for concurrent use
+	activeConnections atomic.Int64
+	totalConnections  atomic.Int64
+	messages          atomic.Int64
+	bytes             atomic.Int64
+	errors            atomic.Int64
+	rejections        atomic.Int64
+
+	// Per-interval counters, reset each time the periodic stats line is logged
+	intervalMessages   atomic.Int64
+	intervalBytes      atomic.Int64
+	intervalErrors     atomic.Int64
+	intervalRejections atomic.Int64
+
+	// Connection durations, bucketed when a connection closes
+	durationUnder1s  atomic.Int64
+	duration1sTo10s  atomic.Int64
+	duration10sTo60s atomic.Int64
+	duration1mTo10m  atomic.Int64
+	durationOver10m  atomic.Int64
+}
+
+var stats serverStats
+
+var maxConcurrent int64 // highest activeConnections seen, accessed atomically
+
+func (st *serverStats) connectionOpened() {
+	active := st.activeConnections.Add(1)
+	st.totalConnections.Add(1)
+
+	for { // raise the watermark if we just passed it
+		peak := atomic.LoadInt64(&maxConcurrent)
+		if active <= peak || atomic.CompareAndSwapInt64(&maxConcurrent, peak, active) {
+			break
+		}
+	}
+}
+
+func (st *serverStats) connectionClosed(duration time.Duration) {
+	st.activeConnections.Add(-1)
+
+	switch {
+	case duration < time.Second:
+		st.durationUnder1s.Add(1)
+	case duration < 10*time.Second:
+		st.duration1sTo10s.Add(1)
+	case duration < time.Minute:
+		st.duration10sTo60s.Add(1)
+	case duration < 10*time.Minute:
+		st.duration1mTo10m.Add(1)
+	default:
+		st.durationOver10m.Add(1)
+	}
+}
+
+const histogramWidth = 20 // characters in the longest bar
+
+func (st *serverStats) durationHistogram() string { // connection durations as a text bar chart
+	buckets := []struct {
+		label string
+		count int64
+	}{
+		{"< 1s", st.durationUnder1s.Load()},
+		{"1s-10s", st.duration1sTo10s.Load()},
+		{"10s-60s", st.duration10sTo60s.Load()},
+		{"1m-10m", st.duration1mTo10m.Load()},
+		{"> 10m", st.durationOver10m.Load()},
+	}
+
+	var most int64
+	for _, b := range buckets {
+		most = max(most, b.count)
+	}
+
+	lines := make([]string, len(buckets))
+	for i, b := range buckets {
+		bar := 0
+		if most > 0 {
+			bar = int(b.count * histogramWidth / most)
+			if b.count > 0 && bar == 0 {
+				bar = 1 // show that the bucket isn't empty
+			}
+		}
+		lines[i] = fmt.Sprintf("%7s: %d |%s", b.label, b.count, strings.Repeat("█", bar))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (st *serverStats) recordMessage(size int) {
+	st.messages.Add(1)
+	st.bytes.Add(int64(size))
+	st.intervalMessages.Add(1)
+	st.intervalBytes.Add(int64(size))
+}
+
+func (st *serverStats) recordError() {
+	st.errors.Add(1)
+	st.intervalErrors.Add(1)
+}
+
+func (st *serverStats) recordRejection() {
+	st.rejections.Add(1)
+	st.intervalRejections.Add(1)
+}
+
+func resetMaxConcurrent() { // restarts the watermark from the current connection count
+	atomic.StoreInt64(&maxConcurrent, stats.activeConnections.Load())
+}
+
+func (st *serverStats) String() string { // multi-line summary used by /stats
+	return fmt.Sprintf("Active connections: %d\nMax concurrent connections: %d\nTotal connections: %d\nMessages: %d\nBytes: %d\nErrors: %d\nRejections: %d",
+		st.activeConnections.Load(),
+		atomic.LoadInt64(&maxConcurrent),
+		st.totalConnections.Load(),
+		st.messages.Load(),
+		st.bytes.Load(),
+		st.errors.Load(),
+		st.rejections.Load()) + "\nConnection durations:\n" + st.durationHistogram()
+}
+
+func logStatsPeriodically(interval time.Duration) { // prints a summary line every interval, runs forever
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		timestamp := logTimestamp()
+		fmt.Printf("[%s] Stats (last %s): active=%d max_concurrent=%d messages=%d bytes=%d errors=%d rejections=%d\n",
+			timestamp, interval,
+			stats.activeConnections.Load(),
+			atomic.LoadInt64(&maxConcurrent),
+			stats.intervalMessages.Swap(0),
+			stats.intervalBytes.Swap(0),
+			stats.intervalErrors.Swap(0),
+			stats.intervalRejections.Swap(0))
+	}
+}
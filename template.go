@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+type templateData struct { // fields available to /echo template
+	Now      time.Time
+	Remote   string
+	Nick     string // always empty until the server has nicknames
+	Seq      int64  // this render's number among the session's /echo template calls, from 1
+	MsgCount int64  // messages received this session, this one included
+}
+
+var errTemplateTooLarge = errors.New("output too large")
+
+func renderTemplate(text string, data templateData) (string, error) { // executes a restricted template, capped at maxMessageSize
+	tmpl, err := template.New("echo").Funcs(template.FuncMap{"printf": boundedPrintf}).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	if len(tmpl.Templates()) > 1 {
+		return "", errors.New("define and block are not allowed")
+	}
+	if err := checkTemplateNodes(tmpl.Tree.Root); err != nil {
+		return "", err
+	}
+
+	out := &cappedBuffer{limit: maxMessageSize - 1}
+	if err := tmpl.Execute(out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func checkTemplateNodes(node parse.Node) error { // rejects range and with, which could loop or nest deeply
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkTemplateNodes(child); err != nil {
+				return err
+			}
+		}
+	case *parse.RangeNode:
+		return errors.New("range is not allowed")
+	case *parse.WithNode:
+		return errors.New("with is not allowed")
+	case *parse.IfNode:
+		if err := checkTemplateNodes(n.List); err != nil {
+			return err
+		}
+		return checkTemplateNodes(n.ElseList)
+	case *parse.TemplateNode:
+		return errors.New("template is not allowed")
+	}
+	return nil
+}
+
+var printfWidth = regexp.MustCompile(`%[-+# 0]*(\d+)?(?:\.(\d+))?`)
+
+const maxPrintfWidth = 100
+
+func boundedPrintf(format string, args ...any) (string, error) { // printf without huge widths like %0999999999d
+	for _, m := range printfWidth.FindAllStringSubmatch(format, -1) {
+		for _, num := range m[1:] {
+			if n, err := strconv.Atoi(num); err == nil && n > maxPrintfWidth {
+				return "", fmt.Errorf("printf width or precision over %d", maxPrintfWidth)
+			}
+		}
+	}
+	return fmt.Sprintf(format, args...), nil
+}
+
+type cappedBuffer struct { // bytes.Buffer that refuses to grow past limit
+	bytes.Buffer
+	limit int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, errTemplateTooLarge
+	}
+	return b.Buffer.Write(p)
+}
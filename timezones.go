@@ -0,0 +1,82 @@
+package main
+
+import (
+	"archive/zip"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var zoneinfoDirs = []string{ // where Go itself looks for the system zone database
+	"/usr/share/zoneinfo",
+	"/usr/share/lib/zoneinfo",
+	"/usr/lib/locale/TZ",
+	"/etc/zoneinfo",
+}
+
+var (
+	timezoneOnce  sync.Once
+	timezoneNames []string
+)
+
+func listTimezones() []string { // sorted IANA zone names, loaded once on first use
+	timezoneOnce.Do(func() {
+		names := make(map[string]struct{})
+		if dir := os.Getenv("ZONEINFO"); dir != "" {
+			addZonesFromDir(dir, names)
+		}
+		for _, dir := range zoneinfoDirs {
+			addZonesFromDir(dir, names)
+		}
+		if len(names) == 0 { // no system database, fall back to the one shipped with Go
+			addZonesFromZip(filepath.Join(runtime.GOROOT(), "lib", "time", "zoneinfo.zip"), names)
+		}
+
+		for name := range names {
+			if _, err := time.LoadLocation(name); err == nil {
+				timezoneNames = append(timezoneNames, name)
+			}
+		}
+		sort.Strings(timezoneNames)
+	})
+	return timezoneNames
+}
+
+func addZonesFromDir(dir string, names map[string]struct{}) {
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		name, err := filepath.Rel(dir, path)
+		if err == nil && looksLikeZoneName(filepath.ToSlash(name)) {
+			names[filepath.ToSlash(name)] = struct{}{}
+		}
+		return nil
+	})
+}
+
+func addZonesFromZip(path string, names map[string]struct{}) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if looksLikeZoneName(f.Name) {
+			names[f.Name] = struct{}{}
+		}
+	}
+}
+
+func looksLikeZoneName(name string) bool { // skips tables, READMEs and the posix/right duplicates
+	if name == "" || strings.Contains(name, ".") || strings.HasPrefix(name, "posix") || strings.HasPrefix(name, "right/") {
+		return false
+	}
+	first := name[0]
+	return first >= 'A' && first <= 'Z' && name != "Factory"
+}
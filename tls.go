@@ -0,0 +1,51 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type vhostCert struct {
+	Cert string `json:"cert"`
+	Key  string `json:"key"`
+}
+
+func loadTLSConfig(certFile, keyFile, vhostPath string) (*tls.Config, error) { // -cert/-key as the default, plus any -vhost-config certificates picked by SNI
+	defaultCert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	conf := &tls.Config{Certificates: []tls.Certificate{defaultCert}}
+	if vhostPath == "" {
+		return conf, nil
+	}
+
+	data, err := os.ReadFile(vhostPath)
+	if err != nil {
+		return nil, err
+	}
+	var vhosts map[string]vhostCert // hostname -> certificate files
+	if err := json.Unmarshal(data, &vhosts); err != nil {
+		return nil, fmt.Errorf("%s: %v", vhostPath, err)
+	}
+
+	certs := make(map[string]*tls.Certificate, len(vhosts))
+	for host, files := range vhosts {
+		cert, err := tls.LoadX509KeyPair(files.Cert, files.Key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: certificate for %s: %v", vhostPath, host, err)
+		}
+		certs[strings.ToLower(host)] = &cert
+	}
+
+	conf.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if cert, ok := certs[strings.ToLower(hello.ServerName)]; ok {
+			return cert, nil
+		}
+		return &defaultCert, nil // no SNI, or a name we don't host
+	}
+	return conf, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const maxSplitTokens = 20
+
+func handleEchoSplit(session *clientSession, sub, args string) error { // split on one character, or on whitespace for split-space
+	var tokens []string
+	if sub == "split-space" {
+		tokens = strings.Fields(args)
+		if len(tokens) == 0 {
+			return writeLine(session, "Usage: /echo split-space <string>")
+		}
+	} else {
+		delim, s := splitArg(args)
+		if utf8.RuneCountInString(delim) != 1 || s == "" {
+			return writeLine(session, "Usage: /echo split <delim> <string>, where delim is a single character")
+		}
+		tokens = strings.Split(s, delim) // consecutive delimiters give empty tokens
+	}
+
+	shown := tokens[:min(len(tokens), maxSplitTokens)]
+	lines := make([]string, len(shown))
+	for i, token := range shown {
+		lines[i] = fmt.Sprintf("%d: %s", i+1, token)
+	}
+	if hidden := len(tokens) - len(shown); hidden > 0 {
+		lines = append(lines, fmt.Sprintf("(%d more not shown)", hidden))
+	}
+	return writeLine(session, strings.Join(lines, "\n"))
+}
+
+func handleEchoJoin(session *clientSession, args string) error {
+	delim, rest := splitArg(args)
+	tokens := splitEscaped(rest)
+	if delim == "" || len(tokens) == 0 {
+		return writeLine(session, `Usage: /echo join <delim> <token> [token ...], with "\ " for a space inside a token`)
+	}
+	joined := strings.Join(tokens, delim)
+	if len(joined)+1 > maxMessageSize {
+		return writeLine(session, fmt.Sprintf("Message cannot be more than %d bytes.", maxMessageSize))
+	}
+	return writeLine(session, joined)
+}
+
+func splitEscaped(s string) []string { // like strings.Fields, but a backslash makes the next character literal
+	var tokens []string
+	var token strings.Builder
+	inToken, escaped := false, false
+	for _, r := range s {
+		switch {
+		case escaped:
+			token.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			inToken, escaped = true, true
+		case r == ' ' || r == '\t':
+			if inToken {
+				tokens = append(tokens, token.String())
+				token.Reset()
+				inToken = false
+			}
+		default:
+			token.WriteRune(r)
+			inToken = true
+		}
+	}
+	if escaped {
+		token.WriteRune('\\') // a trailing backslash escapes nothing, so keep it
+	}
+	if inToken {
+		tokens = append(tokens, token.String())
+	}
+	return tokens
+}
+
+const wordsUsage = "Usage: /echo words unique|sort|count <message>"
+
+func handleEchoWords(session *clientSession, args string) error {
+	mode, message := splitArg(args)
+	words := strings.Fields(message)
+	if len(words) == 0 {
+		return writeLine(session, wordsUsage)
+	}
+	switch mode {
+	case "unique":
+		return writeLine(session, strings.Join(uniqueWords(words), " "))
+	case "sort":
+		sort.SliceStable(words, func(i, j int) bool {
+			return strings.ToLower(words[i]) < strings.ToLower(words[j])
+		})
+		return writeLine(session, strings.Join(words, " "))
+	case "count":
+		return writeLine(session, fmt.Sprintf("%d unique words.", len(uniqueWords(words))))
+	default:
+		return writeLine(session, wordsUsage)
+	}
+}
+
+func uniqueWords(words []string) []string { // drops repeats, keeping the first of each in order; case matters
+	seen := make(map[string]bool, len(words))
+	var unique []string
+	for _, w := range words {
+		if !seen[w] {
+			seen[w] = true
+			unique = append(unique, w)
+		}
+	}
+	return unique
+}
+
+// handleEchoLines works on the lines inside one message. Over TCP a message is
+// whatever one read returned, so lines sent in a single write arrive together;
+// the HTTP API takes newlines in its JSON message as well.
+func handleEchoLines(session *clientSession, args string) error {
+	arg, message := splitArg(args)
+	lines := splitLines(message)
+	if arg == "count" {
+		return writeLine(session, fmt.Sprintf("lines=%d", len(lines)))
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 {
+		return writeLine(session, "Usage: /echo lines count <message> | /echo lines <n> <message>")
+	}
+	if n > len(lines) {
+		return writeLine(session, fmt.Sprintf("Line %d not found.", n))
+	}
+	return writeLine(session, strings.TrimSuffix(lines[n-1], "\r"))
+}
+
+// splitLines splits a message on "\n". An empty message has no lines, so
+// /echo lines and /echo wordcount agree on the count.
+func splitLines(message string) []string {
+	if message == "" {
+		return nil
+	}
+	return strings.Split(message, "\n")
+}
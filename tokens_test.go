@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEchoSplit(t *testing.T) {
+	useTestConfig(t)
+	tests := []struct {
+		name, in, want string
+	}{
+		{"simple", "/echo split , a,b,c", "1: a\n2: b\n3: c\n"},
+		{"consecutive delimiters", "/echo split , a,,b", "1: a\n2: \n3: b\n"},
+		{"only delimiters", "/echo split , ,,", "1: \n2: \n3: \n"},
+		{"delimiter at start", "/echo split , ,a", "1: \n2: a\n"},
+		{"delimiter at end", "/echo split , a,", "1: a\n2: \n"},
+		{"delimiter not present", "/echo split ; a,b", "1: a,b\n"},
+		{"multi-byte delimiter", "/echo split · a·b", "1: a\n2: b\n"},
+		{"spaces kept in tokens", "/echo split , a b, c", "1: a b\n2:  c\n"},
+		{"whitespace", "/echo split-space  a \t b   c", "1: a\n2: b\n3: c\n"},
+		{"delimiter too long", "/echo split ab x", "Usage: /echo split <delim> <string>, where delim is a single character\n"},
+		{"no string", "/echo split ,", "Usage: /echo split <delim> <string>, where delim is a single character\n"},
+		{"split-space without string", "/echo split-space", "Usage: /echo split-space <string>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runCommand(t, tt.in); got != tt.want {
+				t.Errorf("%q replied %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEchoSplitLimit(t *testing.T) {
+	useTestConfig(t)
+	tokens := make([]string, maxSplitTokens+3)
+	for i := range tokens {
+		tokens[i] = fmt.Sprint(i + 1)
+	}
+	reply := runCommand(t, "/echo split , "+strings.Join(tokens, ","))
+	lines := strings.Split(strings.TrimSuffix(reply, "\n"), "\n")
+	if len(lines) != maxSplitTokens+1 || lines[maxSplitTokens-1] != "20: 20" || lines[maxSplitTokens] != "(3 more not shown)" {
+		t.Errorf("23 tokens replied:\n%s", reply)
+	}
+}
+
+func TestSplitEscaped(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"a b c", []string{"a", "b", "c"}},
+		{"  a \t b  ", []string{"a", "b"}},
+		{`a\ b c`, []string{"a b", "c"}},
+		{`\ `, []string{" "}},
+		{`a\\b`, []string{`a\b`}},
+		{`a\\ b`, []string{`a\`, "b"}},
+		{`\x`, []string{"x"}},
+		{`trailing\`, []string{`trailing\`}},
+		{`\\`, []string{`\`}},
+	}
+	for _, tt := range tests {
+		got := splitEscaped(tt.in)
+		if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tt.want) {
+			t.Errorf("splitEscaped(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestJoinSplitRoundTrip(t *testing.T) {
+	useTestConfig(t)
+	for _, tokens := range [][]string{
+		{"a", "b", "c"},
+		{"one"},
+		{"hello world", "x"},
+		{"é", "世界", "🎉"},
+	} {
+		escaped := make([]string, len(tokens))
+		for i, tok := range tokens {
+			escaped[i] = strings.ReplaceAll(tok, " ", `\ `)
+		}
+		joined := strings.TrimSuffix(runCommand(t, "/echo join , "+strings.Join(escaped, " ")), "\n")
+		if want := strings.Join(tokens, ","); joined != want {
+			t.Errorf("join %q = %q, want %q", tokens, joined, want)
+			continue
+		}
+
+		var want strings.Builder
+		for i, tok := range tokens {
+			fmt.Fprintf(&want, "%d: %s\n", i+1, tok)
+		}
+		if split := runCommand(t, "/echo split , "+joined); split != want.String() {
+			t.Errorf("split of %q = %q, want %q", joined, split, want.String())
+		}
+	}
+}
+
+func TestEchoJoinLimits(t *testing.T) {
+	useTestConfig(t)
+	if got := runCommand(t, "/echo join ,"); !strings.HasPrefix(got, "Usage:") {
+		t.Errorf("join with no tokens replied %q", got)
+	}
+	// 300 one-byte tokens joined by a 3-byte delimiter come to 1197 bytes
+	tokens := strings.TrimSpace(strings.Repeat("x ", 300))
+	if got := runCommand(t, "/echo join --- "+tokens); got != fmt.Sprintf("Message cannot be more than %d bytes.\n", maxMessageSize) {
+		t.Errorf("oversized join replied %q", got)
+	}
+}
+
+func TestEchoLines(t *testing.T) {
+	useTestConfig(t)
+	tests := []struct{ in, want string }{
+		{"/echo lines count", "lines=0"},
+		{"/echo lines count single", "lines=1"},
+		{"/echo lines count a\nb\nc", "lines=3"},
+		{"/echo lines count a\n\nb", "lines=3"},
+		{"/echo lines 2 one\ntwo\nthree", "two"},
+		{"/echo lines 2 one\r\ntwo\r\nthree", "two"},
+		{"/echo lines 1 only", "only"},
+		{"/echo lines 4 one\ntwo", "Line 4 not found."},
+		{"/echo lines 1", "Line 1 not found."},
+		{"/echo lines 0 one", "Usage: /echo lines count <message> | /echo lines <n> <message>"},
+	}
+	for _, tt := range tests {
+		if got := runCommand(t, tt.in); got != tt.want+"\n" {
+			t.Errorf("%q replied %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"math/rand"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const zeroWidthJoiner = '\u200d'
+
+func reverseRunes(s string) string { // reverses a string rune by rune, keeping emoji sequences intact
+	clusters := splitClusters(s)
+	for i, j := 0, len(clusters)-1; i < j; i, j = i+1, j-1 {
+		clusters[i], clusters[j] = clusters[j], clusters[i]
+	}
+
+	out := make([]rune, 0, len(s))
+	for _, c := range clusters {
+		out = append(out, c...)
+	}
+	return string(out)
+}
+
+func splitClusters(s string) [][]rune { // groups runes that render as one character
+	var clusters [][]rune
+	joinNext := false
+	for _, r := range s {
+		attach := joinNext || r == zeroWidthJoiner || isModifier(r)
+		if attach && len(clusters) > 0 {
+			last := len(clusters) - 1
+			clusters[last] = append(clusters[last], r)
+		} else {
+			clusters = append(clusters, []rune{r})
+		}
+		joinNext = r == zeroWidthJoiner
+	}
+	return clusters
+}
+
+func isModifier(r rune) bool { // combining marks, variation selectors and skin tones
+	return unicode.Is(unicode.Mn, r) ||
+		(r >= 0xFE00 && r <= 0xFE0F) ||
+		(r >= 0x1F3FB && r <= 0x1F3FF)
+}
+
+func isPalindrome(s string) bool { // compares runes, ignoring case and whitespace
+	var runes []rune
+	for _, r := range strings.ToLower(s) {
+		if !unicode.IsSpace(r) {
+			runes = append(runes, r)
+		}
+	}
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		if runes[i] != runes[j] {
+			return false
+		}
+	}
+	return true
+}
+
+func isAnagram(a, b string) bool { // same runes the same number of times, ignoring case and whitespace
+	counts := make(map[rune]int)
+	for _, r := range strings.ToLower(a) {
+		if !unicode.IsSpace(r) {
+			counts[r]++
+		}
+	}
+	for _, r := range strings.ToLower(b) {
+		if !unicode.IsSpace(r) {
+			counts[r]--
+		}
+	}
+	for _, n := range counts {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func rot13(s string) string { // rotates ASCII letters by 13; every other byte passes through
+	b := []byte(s)
+	for i, c := range b {
+		switch {
+		case c >= 'a' && c <= 'z':
+			b[i] = 'a' + (c-'a'+13)%26
+		case c >= 'A' && c <= 'Z':
+			b[i] = 'A' + (c-'A'+13)%26
+		}
+	}
+	return string(b)
+}
+
+var leetTable = map[rune]rune{'a': '4', 'e': '3', 'i': '1', 'o': '0', 's': '5', 't': '7'}
+
+var unleetTable = func() map[rune]rune {
+	m := make(map[rune]rune, len(leetTable))
+	for letter, digit := range leetTable {
+		m[digit] = letter
+	}
+	return m
+}()
+
+func leet(s string) string { // a, e, i, o, s and t in either case become 4, 3, 1, 0, 5 and 7
+	return strings.Map(func(r rune) rune {
+		if digit, ok := leetTable[unicode.ToLower(r)]; ok {
+			return digit
+		}
+		return r
+	}, s)
+}
+
+func unleet(s string) string { // the inverse of leet; letters come back lowercase
+	return strings.Map(func(r rune) rune {
+		if letter, ok := unleetTable[r]; ok {
+			return letter
+		}
+		return r
+	}, s)
+}
+
+var whitespaceRun = regexp.MustCompile(`\s+`)
+
+func normalizeWhitespace(s string) string { // every run of Unicode whitespace becomes one space, none at the ends
+	ascii := strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return ' ' // \s only matches ASCII whitespace, so convert the rest first
+		}
+		return r
+	}, s)
+	return strings.TrimSpace(whitespaceRun.ReplaceAllString(ascii, " "))
+}
+
+var formatModes = map[string]func(string) string{ // modes selectable with /format
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"rot13": rot13,
+	"leet":  leet,
+}
+
+func applyFormat(mode, message string) string {
+	if transform, ok := formatModes[mode]; ok {
+		return transform(message)
+	}
+	return message
+}
+
+var (
+	scrambleMu    sync.Mutex
+	scrambleSeeds = rand.New(rand.NewSource(cryptoSeed())) // picks a seed for each unseeded /echo scramble
+)
+
+func cryptoSeed() int64 {
+	var b [8]byte
+	crand.Read(b[:])
+	return int64(binary.LittleEndian.Uint64(b[:]))
+}
+
+func nextScrambleSeed() int64 {
+	scrambleMu.Lock()
+	defer scrambleMu.Unlock()
+	return scrambleSeeds.Int63()
+}
+
+func scramble(s string, seed int64) string { // shuffles the runes of s; the same seed always gives the same order
+	runes := []rune(s)
+	rand.New(rand.NewSource(seed)).Shuffle(len(runes), func(i, j int) {
+		runes[i], runes[j] = runes[j], runes[i]
+	})
+	return string(runes)
+}
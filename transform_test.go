@@ -0,0 +1,179 @@
+package main
+
+import (
+	"testing"
+	"testing/quick"
+)
+
+func TestReverseRunes(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"empty", "", ""},
+		{"single", "a", "a"},
+		{"ascii", "hello world", "dlrow olleh"},
+		{"cjk", "你好世界", "界世好你"},
+		{"mixed", "a你b", "b你a"},
+		{"combining mark", "éx", "xé"},
+		{"skin tone", "👍🏽!", "!👍🏽"},
+		{"zwj family", "ab👨‍👩‍👧", "👨‍👩‍👧ba"},
+		{"two zwj sequences", "👩‍💻 👨‍🚀", "👨‍🚀 👩‍💻"},
+		{"variation selector", "❤️x", "x❤️"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reverseRunes(tt.in); got != tt.want {
+				t.Errorf("reverseRunes(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRot13(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"", ""},
+		{"hello", "uryyb"},
+		{"Hello, World!", "Uryyb, Jbeyq!"},
+		{"abcdefghijklmnopqrstuvwxyz", "nopqrstuvwxyzabcdefghijklm"},
+		{"ABCDEFGHIJKLMNOPQRSTUVWXYZ", "NOPQRSTUVWXYZABCDEFGHIJKLM"},
+		{"0123456789 @[`{", "0123456789 @[`{"}, // the bytes either side of each letter range
+		{"héllo 世界", "uéyyb 世界"},
+	}
+	for _, tt := range tests {
+		if got := rot13(tt.in); got != tt.want {
+			t.Errorf("rot13(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRot13RoundTrip(t *testing.T) {
+	ascii := make([]byte, 128)
+	for i := range ascii {
+		ascii[i] = byte(i)
+	}
+	if got := rot13(rot13(string(ascii))); got != string(ascii) {
+		t.Errorf("rot13(rot13(x)) != x for every ASCII byte: got %q", got)
+	}
+
+	if err := quick.Check(func(s string) bool { return rot13(rot13(s)) == s }, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRot13KeepsNonASCII(t *testing.T) {
+	high := make([]byte, 128)
+	for i := range high {
+		high[i] = byte(128 + i) // not valid UTF-8 on its own, which rot13 must not care about
+	}
+	if got := rot13(string(high)); got != string(high) {
+		t.Errorf("rot13 changed bytes >= 0x80: got %q", got)
+	}
+}
+
+func TestFormatRot13(t *testing.T) {
+	if got := applyFormat("rot13", "Hello"); got != "Uryyb" {
+		t.Errorf(`applyFormat("rot13", "Hello") = %q, want "Uryyb"`, got)
+	}
+}
+
+func TestLeet(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"", ""},
+		{"leet", "l337"},
+		{"LEET", "L337"},
+		{"Hello World", "H3ll0 W0rld"},
+		{"aeiost", "431057"},
+		{"AEIOST", "431057"},
+		{"The quick brown fox", "7h3 qu1ck br0wn f0x"},
+		{"Mississippi", "M1551551pp1"},
+		{"xyz bcd", "xyz bcd"},
+		{"1234", "1234"},
+		{"café", "c4fé"}, // é is not e
+		{"ÀÉÎÔ", "ÀÉÎÔ"}, // neither are other accented letters
+		{"señorita", "53ñ0r174"},
+	}
+	for _, tt := range tests {
+		if got := leet(tt.in); got != tt.want {
+			t.Errorf("leet(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestUnleet(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"", ""},
+		{"l337", "leet"},
+		{"H3ll0 W0rld", "Hello World"},
+		{"431057", "aeiost"},
+		{"2689", "2689"}, // digits leet doesn't produce pass through
+	}
+	for _, tt := range tests {
+		if got := unleet(tt.in); got != tt.want {
+			t.Errorf("unleet(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	// Round trip for lowercase text without digits; leet loses case, so that's all it can promise.
+	for _, s := range []string{"leet speak", "the quick brown fox jumps over the lazy dog", "ñandú"} {
+		if got := unleet(leet(s)); got != s {
+			t.Errorf("unleet(leet(%q)) = %q", s, got)
+		}
+	}
+}
+
+func TestFormatLeet(t *testing.T) {
+	if got := applyFormat("leet", "test"); got != "7357" {
+		t.Errorf(`applyFormat("leet", "test") = %q, want "7357"`, got)
+	}
+}
+
+func TestIsPalindrome(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"racecar", true},
+		{"Racecar", true},
+		{"a", true},
+		{"", true},
+		{"never odd or even", true},
+		{"Was it a car or a cat I saw", true},
+		{"été", true},
+		{"たけやぶやけた", true},
+		{"A man a plan a canal Panama", true},
+		{"hello", false},
+		{"ab", false},
+		{"été!", false},
+		{"abca", false},
+	}
+	for _, tt := range tests {
+		if got := isPalindrome(tt.in); got != tt.want {
+			t.Errorf("isPalindrome(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestIsAnagram(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"listen", "silent", true},
+		{"Listen", "Silent", true},
+		{"Dormitory", "dirty room", true},
+		{"", "", true},
+		{"über", "rübe", true},
+		{"Straße", "ßtraes", true},
+		{"минор", "роним", true},
+		{"日本", "本日", true},
+		{"listen", "listens", false},
+		{"aab", "abb", false},
+		{"über", "uber", false}, // ü and u are different runes
+		{"abc", "abd", false},
+	}
+	for _, tt := range tests {
+		if got := isAnagram(tt.a, tt.b); got != tt.want {
+			t.Errorf("isAnagram(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}